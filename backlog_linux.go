@@ -0,0 +1,107 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// setSYNRetries sets the number of SYN retransmissions before connect gives up.
+func setSYNRetries(fd, retries int) error {
+	if err := unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_SYNCNT, retries); err != nil {
+		return os.NewSyscallError("setsockopt", err)
+	}
+	return nil
+}
+
+// synRetransmits returns the number of segments retransmitted by fd, which
+// are all SYNs right after connecting or giving up connecting.
+func synRetransmits(fd int) (int, error) {
+	info, err := unix.GetsockoptTCPInfo(fd, unix.IPPROTO_TCP, unix.TCP_INFO)
+	if err != nil {
+		return 0, os.NewSyscallError("getsockopt", err)
+	}
+	return int(info.Total_retrans), nil
+}
+
+// livenessPort is the port probed to tell whether the host of a listener
+// dropping all the SYNs is up, it is expected to be closed and thus answered
+// with an RST. altLivenessPort is probed instead if the listener is on livenessPort.
+const (
+	livenessPort    = 1
+	altLivenessPort = 2
+)
+
+// livenessSYNRetries makes the probe of the liveness port give up 3 seconds
+// after the first SYN.
+const livenessSYNRetries = 1
+
+// backlogResult converts the result of connect of fd to rAddr in ModeBacklog.
+func (c *Checker) backlogResult(ctx context.Context, fd int, rAddr unix.Sockaddr, family int, err error) error {
+	switch {
+	case err == nil:
+		retransmits, err := synRetransmits(fd)
+		if err != nil {
+			if c.debugEnabled(ctx) {
+				c.log().Debug("tcpshaker: error getting TCP_INFO", "fd", fd, "error", err)
+			}
+			return nil
+		}
+		if retransmits > 0 {
+			return &ErrSYNDropped{Retransmits: retransmits}
+		}
+		return nil
+	case errors.Is(err, unix.ETIMEDOUT):
+		// The kernel gave up after the SYN retries, which are dropped either
+		// by a full listener or on the way to a dead host. The host answering
+		// a SYN to another port tells the former.
+		retransmits, infoErr := synRetransmits(fd)
+		if infoErr != nil || retransmits == 0 || !c.hostAlive(ctx, rAddr, family) {
+			return ErrTimeout
+		}
+		return &ErrSYNDropped{Retransmits: retransmits, Unanswered: true}
+	}
+	return err
+}
+
+// hostAlive returns whether the host of rAddr answers a SYN to the liveness
+// port with an RST or a SYN-ACK before the deadline of ctx.
+func (c *Checker) hostAlive(ctx context.Context, rAddr unix.Sockaddr, family int) bool {
+	fd, err := createSocketMode(family, ModeHalfOpen, true)
+	if err != nil {
+		return false
+	}
+	defer unix.Close(fd)
+	if err := setSYNRetries(fd, livenessSYNRetries); err != nil {
+		return false
+	}
+	success, err := connect(fd, livenessAddr(rAddr))
+	if err == nil && !success {
+		err = c.waitConnectResult(ctx, fd)
+	}
+	if c.debugEnabled(ctx) {
+		c.log().Debug("tcpshaker: probed liveness port", "fd", fd, "error", err)
+	}
+	return err == nil || errors.Is(err, unix.ECONNREFUSED)
+}
+
+// livenessAddr returns the address of the liveness port of the host of rAddr.
+func livenessAddr(rAddr unix.Sockaddr) unix.Sockaddr {
+	switch sa := rAddr.(type) {
+	case *unix.SockaddrInet4:
+		return &unix.SockaddrInet4{Addr: sa.Addr, Port: otherLivenessPort(sa.Port)}
+	case *unix.SockaddrInet6:
+		return &unix.SockaddrInet6{Addr: sa.Addr, ZoneId: sa.ZoneId, Port: otherLivenessPort(sa.Port)}
+	}
+	return rAddr
+}
+
+// otherLivenessPort returns the liveness port other than port.
+func otherLivenessPort(port int) int {
+	if port == livenessPort {
+		return altLivenessPort
+	}
+	return livenessPort
+}
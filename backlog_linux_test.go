@@ -0,0 +1,111 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// listenFull returns a listener with a backlog of 0 whose accept queue is
+// filled by a connection, the SYNs to it are dropped until accept is called.
+func listenFull(t *testing.T) (fd int, addr string) {
+	t.Helper()
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Listen(fd, 0); err != nil {
+		t.Fatal(err)
+	}
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr = (&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: sa.(*syscall.SockaddrInet4).Port}).String()
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return fd, addr
+}
+
+func TestCheckAddrBacklog(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waiting for SYN retransmissions")
+	}
+	t.Parallel()
+	c := NewChecker(WithMode(ModeBacklog), WithSYNRetries(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	// A healthy listener answers the first SYN.
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := c.CheckAddr(l.Addr().String(), time.Second); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+
+	// A listener accepting after a while answers a retransmitted SYN.
+	fd, addr := listenFull(t)
+	time.AfterFunc(300*time.Millisecond, func() {
+		if nfd, _, err := syscall.Accept(fd); err == nil {
+			syscall.Close(nfd)
+		}
+	})
+	err = c.CheckAddr(addr, 2*time.Second)
+	var errSYNDropped *ErrSYNDropped
+	if !errors.As(err, &errSYNDropped) || errSYNDropped.Retransmits != 1 {
+		t.Fatalf("expected ErrSYNDropped, got %v", err)
+	}
+
+	// A listener never accepting drops all the SYNs, the kernel gives up after
+	// the first retransmission 3 seconds after the first SYN, the host answers port 1.
+	_, addr = listenFull(t)
+	startedAt := time.Now()
+	err = c.CheckAddr(addr, 5*time.Second)
+	if !errors.As(err, &errSYNDropped) || !errSYNDropped.Unanswered || errSYNDropped.Retransmits < 1 {
+		t.Fatalf("expected unanswered ErrSYNDropped, got %v", err)
+	}
+	if elapsed := time.Since(startedAt); elapsed > 4*time.Second {
+		t.Fatalf("expected the kernel to give up after the SYN retries, waited %s", elapsed)
+	}
+}
+
+func TestCheckAddrBacklogDeadHost(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waiting for SYN retransmissions")
+	}
+	t.Parallel()
+	c := NewChecker(WithMode(ModeBacklog), WithSYNRetries(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	// Neither the target nor the liveness port of a dead host answers.
+	err := c.CheckAddr(AddrTimeout, 5*time.Second)
+	var errConnect *ErrConnect
+	if err == nil || errors.As(err, &errConnect) {
+		t.Skipf("%s is not a dead host here: %v", AddrTimeout, err)
+	}
+	if err != ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+}
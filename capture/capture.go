@@ -0,0 +1,137 @@
+// Package capture records the TCP segments exchanged with a port, it is used
+// to verify that checks never complete the handshake, i.e. the packet
+// sequence is SYN, SYN-ACK, RST instead of SYN, SYN-ACK, ACK.
+//
+// Capturing requires an AF_PACKET socket, which is only available on Linux
+// with CAP_NET_RAW.
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/tevino/tcp-shaker/internal/rawtcp"
+)
+
+// ErrUnsupported is returned by Start on platforms without AF_PACKET.
+var ErrUnsupported = errors.New("capture: packet capturing is not supported on this platform")
+
+// Packet is a captured TCP segment.
+type Packet struct {
+	Time  time.Time
+	Src   netip.AddrPort
+	Dst   netip.AddrPort
+	Seq   uint32
+	Ack   uint32
+	Flags uint8
+}
+
+// Kind describes the flags of the segment, e.g. "SYN", "SYN-ACK", "ACK" and "RST".
+// Flags other than SYN, FIN, RST, PSH and ACK are ignored.
+func (p Packet) Kind() string {
+	names := []struct {
+		flag uint8
+		name string
+	}{
+		{rawtcp.FlagSYN, "SYN"},
+		{rawtcp.FlagFIN, "FIN"},
+		{rawtcp.FlagRST, "RST"},
+		{rawtcp.FlagPSH, "PSH"},
+		{rawtcp.FlagACK, "ACK"},
+	}
+	var kinds []string
+	for _, n := range names {
+		if p.Flags&n.flag != 0 {
+			kinds = append(kinds, n.name)
+		}
+	}
+	if len(kinds) == 0 {
+		return "NONE"
+	}
+	return strings.Join(kinds, "-")
+}
+
+func (p Packet) String() string {
+	return fmt.Sprintf("%s > %s %s seq=%d ack=%d", p.Src, p.Dst, p.Kind(), p.Seq, p.Ack)
+}
+
+// Kinds returns the kinds of given packets in order.
+func Kinds(packets []Packet) []string {
+	kinds := make([]string, len(packets))
+	for i, p := range packets {
+		kinds[i] = p.Kind()
+	}
+	return kinds
+}
+
+// Handshake returns the packets of the connection started by the first SYN
+// sent to the port, i.e. the ones between its client address and the port.
+func Handshake(packets []Packet, port uint16) []Packet {
+	var client netip.AddrPort
+	var conn []Packet
+	for _, p := range packets {
+		if !client.IsValid() {
+			if p.Dst.Port() != port || p.Flags&rawtcp.FlagSYN == 0 || p.Flags&rawtcp.FlagACK != 0 {
+				continue
+			}
+			client = p.Src
+		}
+		if p.Src == client || p.Dst == client {
+			conn = append(conn, p)
+		}
+	}
+	return conn
+}
+
+// Completed returns true if the client acknowledged the SYN-ACK, which
+// means the connection was established from the view of the server.
+func Completed(handshake []Packet) bool {
+	if len(handshake) == 0 {
+		return false
+	}
+	client := handshake[0].Src
+	var synAck *Packet
+	for i, p := range handshake {
+		switch {
+		case p.Src != client && p.Flags&(rawtcp.FlagSYN|rawtcp.FlagACK) == rawtcp.FlagSYN|rawtcp.FlagACK:
+			synAck = &handshake[i]
+		case synAck != nil && p.Src == client && p.Flags&rawtcp.FlagRST == 0 &&
+			p.Flags&rawtcp.FlagACK != 0 && p.Ack == synAck.Seq+1:
+			return true
+		}
+	}
+	return false
+}
+
+// Aborted returns true if the handshake is SYN, SYN-ACK followed only by the
+// RSTs of the client, which is the sequence expected from a health check.
+func Aborted(handshake []Packet) bool {
+	if len(handshake) < 3 {
+		return false
+	}
+	client := handshake[0].Src
+	synAck := handshake[1]
+	if synAck.Src == client || synAck.Flags&(rawtcp.FlagSYN|rawtcp.FlagACK) != rawtcp.FlagSYN|rawtcp.FlagACK {
+		return false
+	}
+	for _, p := range handshake[2:] {
+		if p.Src != client || p.Flags&rawtcp.FlagRST == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func newPacket(t time.Time, src, dst netip.Addr, seg *rawtcp.Segment) Packet {
+	return Packet{
+		Time:  t,
+		Src:   netip.AddrPortFrom(src, seg.SrcPort),
+		Dst:   netip.AddrPortFrom(dst, seg.DstPort),
+		Seq:   seg.Seq,
+		Ack:   seg.Ack,
+		Flags: seg.Flags,
+	}
+}
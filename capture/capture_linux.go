@@ -0,0 +1,157 @@
+package capture
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tevino/tcp-shaker/internal/rawtcp"
+	"golang.org/x/sys/unix"
+)
+
+// Capture records the TCP segments from and to a port on all interfaces.
+type Capture struct {
+	fd      int
+	port    uint16
+	lock    sync.Mutex
+	packets []Packet
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// pollTimeout is the interval the capturing goroutine checks whether to stop.
+const pollTimeout = 10 * time.Millisecond
+
+// Start starts capturing the TCP segments from and to given port,
+// call Stop to get the captured packets.
+func Start(port uint16) (*Capture, error) {
+	// Receive nothing until the filter is attached.
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	prog := filter(port)
+	fprog := unix.SockFprog{Len: uint16(len(prog)), Filter: &prog[0]}
+	if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &fprog); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("setsockopt", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ALL)}); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	tv := unix.NsecToTimeval(pollTimeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("setsockopt", err)
+	}
+	c := &Capture{fd: fd, port: port, done: make(chan struct{}), stopped: make(chan struct{})}
+	go c.loop()
+	return c, nil
+}
+
+func (c *Capture) loop() {
+	defer close(c.stopped)
+	var buf [256]byte
+	for {
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		n, from, err := unix.Recvfrom(c.fd, buf[:], unix.MSG_TRUNC)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return
+		}
+		now := time.Now()
+		if ll, ok := from.(*unix.SockaddrLinklayer); ok &&
+			ll.Hatype == unix.ARPHRD_LOOPBACK && ll.Pkttype == unix.PACKET_OUTGOING {
+			// Packets on loopback are seen twice, once sent and once received.
+			continue
+		}
+		c.record(now, buf[:min(n, len(buf))])
+	}
+}
+
+func (c *Capture) record(t time.Time, b []byte) {
+	var err error
+	var p Packet
+	switch {
+	case len(b) > 0 && b[0]>>4 == 4:
+		src, dst, seg, perr := rawtcp.ParseIPv4(b)
+		if err = perr; err == nil {
+			p = newPacket(t, src, dst, seg)
+		}
+	case len(b) > 0 && b[0]>>4 == 6:
+		src, dst, seg, perr := rawtcp.ParseIPv6(b)
+		if err = perr; err == nil {
+			p = newPacket(t, src, dst, seg)
+		}
+	default:
+		return
+	}
+	if err != nil || (p.Src.Port() != c.port && p.Dst.Port() != c.port) {
+		return
+	}
+	c.lock.Lock()
+	c.packets = append(c.packets, p)
+	c.lock.Unlock()
+}
+
+// Stop stops capturing and returns the captured packets in order.
+func (c *Capture) Stop() []Packet {
+	close(c.done)
+	<-c.stopped
+	unix.Close(c.fd)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.packets
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// filter returns a cBPF program accepting the TCP segments from or to port,
+// IPv4 fragments and IPv6 extension headers are not supported.
+// The offsets are relative to the network header of SOCK_DGRAM sockets,
+// the jump offsets are relative to the next instruction.
+func filter(port uint16) []unix.SockFilter {
+	const (
+		// SKF_AD_OFF + SKF_AD_PROTOCOL
+		adProtocol = 0xfffff000
+		snapLen    = 0x40000
+	)
+	p := uint32(port)
+	ins := func(code uint16, jt, jf uint8, k uint32) unix.SockFilter {
+		return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+	return []unix.SockFilter{
+		/* 0 */ ins(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, 0, 0, adProtocol),
+		/* 1 */ ins(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, 0, 9, uint32(unix.ETH_P_IP)), // else 11
+		// IPv4
+		/* 2 */ ins(unix.BPF_LD|unix.BPF_B|unix.BPF_ABS, 0, 0, 9),
+		/* 3 */ ins(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, 0, 15, unix.IPPROTO_TCP), // else drop
+		/* 4 */ ins(unix.BPF_LD|unix.BPF_H|unix.BPF_ABS, 0, 0, 6),
+		/* 5 */ ins(unix.BPF_JMP|unix.BPF_JSET|unix.BPF_K, 13, 0, 0x1fff), // fragment: drop
+		/* 6 */ ins(unix.BPF_LDX|unix.BPF_B|unix.BPF_MSH, 0, 0, 0),
+		/* 7 */ ins(unix.BPF_LD|unix.BPF_H|unix.BPF_IND, 0, 0, 0),
+		/* 8 */ ins(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, 9, 0, p), // accept
+		/* 9 */ ins(unix.BPF_LD|unix.BPF_H|unix.BPF_IND, 0, 0, 2),
+		/* 10 */ ins(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, 7, 8, p), // accept or drop
+		// IPv6
+		/* 11 */ ins(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, 0, 7, uint32(unix.ETH_P_IPV6)), // else drop
+		/* 12 */ ins(unix.BPF_LD|unix.BPF_B|unix.BPF_ABS, 0, 0, 6),
+		/* 13 */ ins(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, 0, 5, unix.IPPROTO_TCP), // else drop
+		/* 14 */ ins(unix.BPF_LD|unix.BPF_H|unix.BPF_ABS, 0, 0, 40),
+		/* 15 */ ins(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, 2, 0, p), // accept
+		/* 16 */ ins(unix.BPF_LD|unix.BPF_H|unix.BPF_ABS, 0, 0, 42),
+		/* 17 */ ins(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, 0, 1, p), // accept or drop
+		/* 18 */ ins(unix.BPF_RET|unix.BPF_K, 0, 0, snapLen),
+		/* 19 */ ins(unix.BPF_RET|unix.BPF_K, 0, 0, 0),
+	}
+}
@@ -0,0 +1,64 @@
+package capture
+
+import (
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCaptureHandshake(t *testing.T) {
+	for network, addr := range map[string]string{"tcp4": "127.0.0.1:0", "tcp6": "[::1]:0"} {
+		t.Run(network, func(t *testing.T) {
+			l, err := net.Listen(network, addr)
+			if err != nil {
+				t.Skipf("%s is not available: %v", network, err)
+			}
+			defer l.Close()
+			port := netip.MustParseAddrPort(l.Addr().String()).Port()
+
+			c, err := Start(port)
+			if err != nil {
+				t.Skipf("packet capturing is not available: %v", err)
+			}
+			conn, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				c.Stop()
+				t.Fatal(err)
+			}
+			conn.Close()
+			time.Sleep(50 * time.Millisecond)
+			packets := Handshake(c.Stop(), port)
+
+			kinds := Kinds(packets)
+			if len(kinds) < 3 || !reflect.DeepEqual(kinds[:3], []string{"SYN", "SYN-ACK", "ACK"}) {
+				t.Fatalf("unexpected packets: %v", packets)
+			}
+			if !Completed(packets) {
+				t.Fatal("the handshake is not reported as completed")
+			}
+		})
+	}
+}
+
+func TestFilterIgnoresOtherPorts(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c, err := Start(1)
+	if err != nil {
+		t.Skipf("packet capturing is not available: %v", err)
+	}
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err == nil {
+		conn.Close()
+	}
+	time.Sleep(50 * time.Millisecond)
+	if packets := c.Stop(); len(packets) != 0 {
+		t.Fatalf("unexpected packets: %v", packets)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package capture
+
+// Capture records the TCP segments from and to a port on all interfaces.
+type Capture struct{}
+
+// Start returns ErrUnsupported on this platform.
+func Start(port uint16) (*Capture, error) {
+	return nil, ErrUnsupported
+}
+
+// Stop returns nothing on this platform.
+func (c *Capture) Stop() []Packet {
+	return nil
+}
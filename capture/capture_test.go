@@ -0,0 +1,46 @@
+package capture
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/tevino/tcp-shaker/internal/rawtcp"
+)
+
+func TestHandshakeVerdict(t *testing.T) {
+	client := netip.MustParseAddrPort("127.0.0.1:40000")
+	server := netip.MustParseAddrPort("127.0.0.1:80")
+	other := netip.MustParseAddrPort("127.0.0.1:40001")
+	syn := Packet{Src: client, Dst: server, Seq: 10, Flags: rawtcp.FlagSYN}
+	synAck := Packet{Src: server, Dst: client, Seq: 20, Ack: 11, Flags: rawtcp.FlagSYN | rawtcp.FlagACK}
+	ack := Packet{Src: client, Dst: server, Seq: 11, Ack: 21, Flags: rawtcp.FlagACK}
+	finAck := Packet{Src: client, Dst: server, Seq: 11, Ack: 21, Flags: rawtcp.FlagFIN | rawtcp.FlagACK}
+	rst := Packet{Src: client, Dst: server, Seq: 11, Flags: rawtcp.FlagRST}
+	rstAck := Packet{Src: client, Dst: server, Seq: 11, Ack: 21, Flags: rawtcp.FlagRST | rawtcp.FlagACK}
+	// packets of other connections
+	before := Packet{Src: other, Dst: server, Seq: 1, Flags: rawtcp.FlagACK}
+	after := Packet{Src: other, Dst: server, Seq: 1, Flags: rawtcp.FlagSYN}
+
+	for _, c := range []struct {
+		packets   []Packet
+		kinds     []string
+		aborted   bool
+		completed bool
+	}{
+		{[]Packet{syn, synAck, rst}, []string{"SYN", "SYN-ACK", "RST"}, true, false},
+		{[]Packet{syn, synAck, rstAck}, []string{"SYN", "SYN-ACK", "RST-ACK"}, true, false},
+		{[]Packet{syn, synAck, rst, rst}, []string{"SYN", "SYN-ACK", "RST", "RST"}, true, false},
+		{[]Packet{syn, synAck, ack, rst}, []string{"SYN", "SYN-ACK", "ACK", "RST"}, false, true},
+		{[]Packet{syn, synAck, finAck}, []string{"SYN", "SYN-ACK", "FIN-ACK"}, false, true},
+		{[]Packet{syn, synAck}, []string{"SYN", "SYN-ACK"}, false, false},
+	} {
+		packets := Handshake(append([]Packet{synAck, before}, append(c.packets, after)...), server.Port())
+		if kinds := Kinds(packets); !reflect.DeepEqual(kinds, c.kinds) {
+			t.Fatalf("expected %v, got %v", c.kinds, kinds)
+		}
+		if Aborted(packets) != c.aborted || Completed(packets) != c.completed {
+			t.Fatalf("unexpected verdict of %v: aborted=%v completed=%v", c.kinds, Aborted(packets), Completed(packets))
+		}
+	}
+}
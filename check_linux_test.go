@@ -1,9 +1,14 @@
 package tcp
 
 import (
+	"bytes"
 	"context"
+	"log/slog"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/tevino/tcp-shaker/internal"
 )
 
 func TestCheckerReadyOK(t *testing.T) {
@@ -82,3 +87,36 @@ func _testChecker(t *testing.T, c *Checker) {
 	err = c.CheckAddr(AddrTimeout, timeout)
 	assert(t, err == ErrTimeout)
 }
+
+func TestCheckerLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := NewChecker(WithLogger(logger))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	addr, stop := StartTestServer()
+	defer stop()
+	assert(t, c.CheckAddr(addr, time.Second) == nil)
+	assert(t, strings.Contains(buf.String(), "socket created"))
+
+	// events of unknown fds are logged
+	c.handlePollerEvents([]internal.Event{{Fd: -1}})
+	assert(t, strings.Contains(buf.String(), "spurious event for unknown fd"))
+}
+
+func TestCheckerDefaultLogger(t *testing.T) {
+	c := NewChecker()
+
+	// slog.SetDefault after NewChecker applies to c
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	c.handlePollerEvents([]internal.Event{{Fd: -1}})
+	assert(t, strings.Contains(buf.String(), "spurious event for unknown fd"))
+}
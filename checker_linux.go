@@ -2,6 +2,7 @@ package tcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -18,26 +19,30 @@ type Checker struct {
 	resultPipes internal.ResultPipes
 	pollerLock  sync.Mutex
 	_pollerFd   int32
-	zeroLinger  bool
 	isReady     chan struct{}
+	statusLock  sync.Mutex
+	status      LoopStatus
+	synProber   atomic.Pointer[synProber]
+	config
 }
 
-// NewChecker creates a Checker with linger set to zero.
-func NewChecker() *Checker {
-	return NewCheckerZeroLinger(true)
-}
-
-// NewCheckerZeroLinger creates a Checker with zeroLinger set to given value.
-func NewCheckerZeroLinger(zeroLinger bool) *Checker {
+// NewChecker creates a Checker with given options.
+// Linger is set to zero unless WithZeroLinger(false) is given.
+func NewChecker(opts ...Option) *Checker {
 	return &Checker{
 		pipePool:    internal.NewPipePoolSyncPool(),
 		resultPipes: internal.NewResultPipesSyncMap(),
 		_pollerFd:   -1,
-		zeroLinger:  zeroLinger,
 		isReady:     make(chan struct{}),
+		config:      newConfig(opts...),
 	}
 }
 
+// NewCheckerZeroLinger creates a Checker with zeroLinger set to given value.
+func NewCheckerZeroLinger(zeroLinger bool) *Checker {
+	return NewChecker(WithZeroLinger(zeroLinger))
+}
+
 // CheckingLoop must be called before anything else.
 // NOTE: this function blocks until ctx got canceled.
 func (c *Checker) CheckingLoop(ctx context.Context) error {
@@ -49,10 +54,31 @@ func (c *Checker) CheckingLoop(ctx context.Context) error {
 		_ = c.closePoller()
 	}()
 
+	if c.rawSYN {
+		prober, err := newSYNProber()
+		if err != nil {
+			return fmt.Errorf("error creating raw sockets: %w", err)
+		}
+		defer prober.close()
+		if err := prober.register(pollerFd); err != nil {
+			return err
+		}
+		c.synProber.Store(prober)
+		defer c.synProber.Store(nil)
+	}
+
 	c.setReady()
 	defer c.resetReady()
 
-	return c.pollingLoop(ctx, pollerFd)
+	if c.supervisor == nil {
+		err = c.pollingLoop(ctx, pollerFd)
+	} else {
+		err = c.supervisedPollingLoop(ctx, pollerFd)
+	}
+	if err != nil {
+		c.observer.OnLoopError(err)
+	}
+	return err
 }
 
 func (c *Checker) createPoller() (int, error) {
@@ -103,7 +129,10 @@ func (c *Checker) pollingLoop(ctx context.Context, pollerFd int) error {
 			evts, err := pollEvents(pollerFd, pollerTimeout)
 			if err != nil {
 				// fatal error
-				return fmt.Errorf("error during polling loop: %w", err)
+				c.log().Debug("tcpshaker: error polling events", "poller_fd", pollerFd, "error", err)
+				err = fmt.Errorf("error during polling loop: %w", err)
+				c.setLastError(err)
+				return err
 			}
 
 			c.handlePollerEvents(evts)
@@ -112,12 +141,22 @@ func (c *Checker) pollingLoop(ctx context.Context, pollerFd int) error {
 }
 
 func (c *Checker) handlePollerEvents(evts []internal.Event) {
+	prober := c.synProber.Load()
 	for _, e := range evts {
+		if prober != nil && prober.owns(e.Fd) {
+			prober.receive(e.Fd)
+			continue
+		}
+		c.observer.OnPollerEvent(e.Fd, e.Err)
 		if pipe, exists := c.resultPipes.PopResultPipe(e.Fd); exists {
 			pipe <- e.Err
+			continue
 		}
 		// error pipe not found
 		// in this case, e.Fd should have been handled in the previous event.
+		if c.debugEnabled(context.Background()) {
+			c.log().Debug("tcpshaker: spurious event for unknown fd", "fd", e.Fd, "error", e.Err)
+		}
 	}
 }
 
@@ -135,6 +174,8 @@ func (c *Checker) setPollerFD(fd int) {
 // zeroLinger is an optional parameter indicating if linger should be set to zero
 // for this particular connection
 // Note: timeout includes domain resolving
+// Unix domain sockets are checked with addresses like "unix:///run/app.sock" and
+// "unix:@app", see ParseUnixAddr, ErrSocketNotFound and ErrBacklogFull.
 func (c *Checker) CheckAddr(addr string, timeout time.Duration) (err error) {
 	return c.CheckAddrZeroLinger(addr, timeout, c.zeroLinger)
 }
@@ -142,35 +183,99 @@ func (c *Checker) CheckAddr(addr string, timeout time.Duration) (err error) {
 // CheckAddrZeroLinger is like CheckAddr with an extra parameter indicating whether to enable zero linger.
 func (c *Checker) CheckAddrZeroLinger(addr string, timeout time.Duration, zeroLinger bool) error {
 	// Set deadline
-	deadline := time.Now().Add(timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.checkAddr(ctx, addr, c.mode, zeroLinger)
+}
+
+// CheckAddrContext is like CheckAddr but the check is bound to given ctx.
+// ErrTimeout is returned if the deadline of ctx exceeded,
+// ctx.Err() is returned if ctx is canceled.
+// NOTE: without a deadline set on ctx, the check waits until ctx is canceled.
+func (c *Checker) CheckAddrContext(ctx context.Context, addr string) error {
+	return c.checkAddr(ctx, addr, c.mode, c.zeroLinger)
+}
+
+// CheckAddrMode is like CheckAddrContext but the check is performed in given mode
+// instead of the one set by WithMode.
+func (c *Checker) CheckAddrMode(ctx context.Context, addr string, mode Mode) error {
+	return c.checkAddr(ctx, addr, mode, c.zeroLinger)
+}
+
+func (c *Checker) checkAddr(ctx context.Context, addr string, mode Mode, zeroLinger bool) (err error) {
+	startedAt := time.Now()
+	ctx = c.observer.OnCheckStart(contextWithMode(ctx, mode), addr)
+	defer func() {
+		c.localStats.count(err)
+		c.observer.OnCheckDone(ctx, err, time.Since(startedAt))
+	}()
+	if name, ok := ParseUnixAddr(addr); ok {
+		return c.checkUnix(ctx, name)
+	}
+	if mode == ModeUDP {
+		return c.checkUDP(ctx, addr, c.udpPayload)
+	}
 
 	// Parse address
 	rAddr, family, err := parseSockAddr(addr)
 	if err != nil {
 		return err
 	}
+	c.observer.OnResolved(ctx, sockaddrToTCPAddr(rAddr))
+	if c.rawSYN && mode == ModeHalfOpen {
+		return c.checkAddrSYN(ctx, rAddr, family)
+	}
 	// Create socket with options set
-	fd, err := createSocketZeroLinger(family, zeroLinger)
+	zeroLinger = mode.zeroLinger(zeroLinger)
+	fd, err := createSocketMode(family, mode, zeroLinger)
 	if err != nil {
+		if c.debugEnabled(ctx) {
+			c.log().Debug("tcpshaker: error creating socket", "addr", addr, "error", err)
+		}
+		if localErr := localError(err); localErr != nil {
+			return localErr
+		}
 		return err
 	}
 	// Socket should be closed anyway
 	defer unix.Close(fd)
+	if c.debugEnabled(ctx) {
+		c.log().Debug("tcpshaker: socket created", "addr", addr, "fd", fd, "mode", mode, "zero_linger", zeroLinger)
+	}
+	c.observer.OnSocketCreated(ctx, fd)
+	if mode == ModeBacklog {
+		if err := setSYNRetries(fd, c.synRetries); err != nil {
+			return err
+		}
+	}
+	if c.sourcePool != nil {
+		if err := c.bindSource(fd, family); err != nil {
+			if c.debugEnabled(ctx) {
+				c.log().Debug("tcpshaker: error binding source address", "fd", fd, "error", err)
+			}
+			return err
+		}
+	}
 
 	// Connect to the address
-	if success, cErr := connect(fd, rAddr); cErr != nil {
-		// If there was an error, return it.
-		return &ErrConnect{cErr}
-	} else if success {
-		// If the connect was successful, we are done.
-		return nil
+	success, cErr := connect(fd, rAddr)
+	c.observer.OnConnectIssued(ctx, fd, cErr)
+	if cErr != nil {
+		// If there was an error, return it, EADDRNOTAVAIL means the
+		// ephemeral ports are exhausted rather than a failure of the target.
+		return localOrConnectError(cErr)
 	}
-	// Otherwise wait for the result of connect.
-
-	return c.waitConnectResult(fd, time.Until((deadline)))
+	if !success {
+		// Otherwise wait for the result of connect.
+		err = c.waitConnectResult(ctx, fd)
+	}
+	if mode == ModeBacklog {
+		return c.backlogResult(ctx, fd, rAddr, family, err)
+	}
+	return err
 }
 
-func (c *Checker) waitConnectResult(fd int, timeout time.Duration) error {
+func (c *Checker) waitConnectResult(ctx context.Context, fd int) error {
 	// get a pipe of connect result
 	resultPipe := c.pipePool.GetPipe()
 	defer func() {
@@ -181,20 +286,39 @@ func (c *Checker) waitConnectResult(fd int, timeout time.Duration) error {
 	// this must be done before registerEvents
 	c.resultPipes.RegisterResultPipe(fd, resultPipe)
 	// Register to epoll for later error checking
-	if err := registerEvents(c.pollerFD(), fd); err != nil {
+	if err := c.registerCheck(fd, registerEvents); err != nil {
+		if c.debugEnabled(ctx) {
+			c.log().Debug("tcpshaker: error registering events", "fd", fd, "error", err)
+		}
 		return err
 	}
 
 	// Wait for connect result
-	return c.waitPipeTimeout(resultPipe, timeout)
+	return c.waitPipe(ctx, resultPipe)
+}
+
+// registerCheck registers fd of a check to the poller with register. It is
+// retried if the poller is replaced by the supervisor meanwhile, EEXIST means
+// the supervisor has registered fd to the new poller already.
+func (c *Checker) registerCheck(fd int, register func(pollerFd, fd int) error) error {
+	for {
+		pollerFd := c.pollerFD()
+		err := register(pollerFd, fd)
+		if err == nil || errors.Is(err, unix.EEXIST) {
+			return nil
+		}
+		if c.pollerFD() == pollerFd {
+			return err
+		}
+	}
 }
 
-func (c *Checker) waitPipeTimeout(pipe chan error, timeout time.Duration) error {
+func (c *Checker) waitPipe(ctx context.Context, pipe chan error) error {
 	select {
 	case ret := <-pipe:
 		return ret
-	case <-time.After(timeout):
-		return ErrTimeout
+	case <-ctx.Done():
+		return ctxErr(ctx)
 	}
 }
 
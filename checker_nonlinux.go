@@ -5,26 +5,30 @@ package tcp
 
 import (
 	"context"
+	"errors"
 	"net"
+	"os"
+	"syscall"
 	"time"
 )
 
 // Checker is a fake implementation.
 type Checker struct {
-	zeroLinger bool
-	isReady    chan struct{}
+	isReady chan struct{}
+	config
 }
 
-// NewChecker creates a Checker with linger set to zero.
-func NewChecker() *Checker {
-	return NewCheckerZeroLinger(true)
+// NewChecker creates a Checker with given options.
+// Linger is set to zero unless WithZeroLinger(false) is given.
+func NewChecker(opts ...Option) *Checker {
+	isReady := make(chan struct{})
+	close(isReady)
+	return &Checker{isReady: isReady, config: newConfig(opts...)}
 }
 
 // NewCheckerZeroLinger creates a Checker with zeroLinger set to given value.
 func NewCheckerZeroLinger(zeroLinger bool) *Checker {
-	isReady := make(chan struct{})
-	close(isReady)
-	return &Checker{zeroLinger: zeroLinger, isReady: isReady}
+	return NewChecker(WithZeroLinger(zeroLinger))
 }
 
 // CheckingLoop is unnecessary on this platform.
@@ -36,20 +40,79 @@ func (c *Checker) CheckingLoop(ctx context.Context) error {
 // CheckAddr performs a TCP check with given TCP address and timeout.
 // NOTE: zeroLinger is ignored on non-POSIX operating systems because
 // net.TCPConn.SetLinger is only implemented in src/net/sockopt_posix.go.
+// Unix domain sockets are checked with addresses like "unix:///run/app.sock",
+// see ParseUnixAddr.
 func (c *Checker) CheckAddr(addr string, timeout time.Duration) error {
 	return c.CheckAddrZeroLinger(addr, timeout, c.zeroLinger)
 }
 
 // CheckAddrZeroLinger is CheckerAddr with a zeroLinger parameter.
+// NOTE: only OnCheckStart and OnCheckDone of the Observer are called on this platform.
 func (c *Checker) CheckAddrZeroLinger(addr string, timeout time.Duration, zeroLinger bool) error {
-	conn, err := net.DialTimeout("tcp", addr, timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.checkAddr(ctx, addr, c.mode, zeroLinger)
+}
+
+// CheckAddrContext is like CheckAddr but the check is bound to given ctx.
+func (c *Checker) CheckAddrContext(ctx context.Context, addr string) error {
+	return c.checkAddr(ctx, addr, c.mode, c.zeroLinger)
+}
+
+// CheckAddrMode is like CheckAddrContext but the check is performed in given mode
+// instead of the one set by WithMode.
+// NOTE: the handshake is always completed on this platform, mode only decides
+// whether the connection is closed with a FIN or an RST. The SYN retransmissions
+// are not inspected in ModeBacklog.
+func (c *Checker) CheckAddrMode(ctx context.Context, addr string, mode Mode) error {
+	return c.checkAddr(ctx, addr, mode, c.zeroLinger)
+}
+
+func (c *Checker) checkAddr(ctx context.Context, addr string, mode Mode, zeroLinger bool) (err error) {
+	startedAt := time.Now()
+	ctx = c.observer.OnCheckStart(contextWithMode(ctx, mode), addr)
+	defer func() {
+		c.localStats.count(err)
+		c.observer.OnCheckDone(ctx, err, time.Since(startedAt))
+	}()
+
+	if name, ok := ParseUnixAddr(addr); ok {
+		return c.checkUnix(ctx, name)
+	}
+	switch mode {
+	case ModeUDP:
+		return c.checkUDP(ctx, addr, c.udpPayload)
+	case ModeSCTP:
+		// SCTP is not supported by the net package.
+		return &ErrConnect{syscall.EPROTONOSUPPORT}
+	}
+
+	var dialer net.Dialer
+	if c.sourcePool != nil {
+		// The family of the target decides the source address.
+		tAddr, err := net.ResolveTCPAddr("tcp", addr)
+		if err != nil {
+			return err
+		}
+		if src, ok := c.sourcePool.nextAddr(tAddr.IP.To4() != nil); ok {
+			dialer.LocalAddr = net.TCPAddrFromAddrPort(src)
+		}
+		addr = tAddr.String()
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
 	if conn != nil {
-		if zeroLinger {
+		if mode.zeroLinger(zeroLinger) {
 			// Simply ignore the error since this is a fake implementation.
 			_ = conn.(*net.TCPConn).SetLinger(0)
 		}
 		_ = conn.Close()
 	}
+	if err != nil && ctx.Err() != nil {
+		return ctxErr(ctx)
+	}
+	if localErr := localError(err); localErr != nil {
+		return localErr
+	}
 	if opErr, ok := err.(*net.OpError); ok {
 		if opErr.Timeout() {
 			return ErrTimeout
@@ -58,6 +121,72 @@ func (c *Checker) CheckAddrZeroLinger(addr string, timeout time.Duration, zeroLi
 	return err
 }
 
+// CheckUDP checks a UDP port by sending payload from a connected UDP socket.
+// A response means the port is open, nil is returned.
+// An ICMP port unreachable means it is closed, ErrConnect wrapping
+// ECONNREFUSED is returned.
+// ErrOpenFiltered is returned if nothing is received before the deadline of ctx.
+func (c *Checker) CheckUDP(ctx context.Context, addr string, payload []byte) (err error) {
+	startedAt := time.Now()
+	ctx = c.observer.OnCheckStart(contextWithMode(ctx, ModeUDP), addr)
+	defer func() {
+		c.localStats.count(err)
+		c.observer.OnCheckDone(ctx, err, time.Since(startedAt))
+	}()
+	return c.checkUDP(ctx, addr, payload)
+}
+
+func (c *Checker) checkUDP(ctx context.Context, addr string, payload []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		if localErr := localError(err); localErr != nil {
+			return localErr
+		}
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err = conn.Write(payload); err == nil {
+		// The buffer is large enough for any datagram, which fails to be
+		// read partially on some platforms.
+		buf := make([]byte, 65536)
+		_, err = conn.Read(buf)
+	}
+	var errno syscall.Errno
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(ctx.Err(), context.Canceled):
+		return ctx.Err()
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrOpenFiltered
+	case errors.As(err, &errno):
+		return &ErrConnect{errno}
+	}
+	return err
+}
+
+func (c *Checker) checkUnix(ctx context.Context, name string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", name)
+	if err == nil {
+		_ = conn.Close()
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctxErr(ctx)
+	}
+	return unixConnectError(err)
+}
+
 // IsReady is always true on this platform.
 func (c *Checker) IsReady() bool { return true }
 
@@ -66,5 +195,8 @@ func (c *Checker) WaitReady() <-chan struct{} {
 	return c.isReady
 }
 
+// LoopStatus always reports a running loop on this platform.
+func (c *Checker) LoopStatus() LoopStatus { return LoopStatus{Running: true} }
+
 // Close is unnecessary on this platform.
 func (c *Checker) Close() error { return nil }
@@ -2,6 +2,7 @@ package tcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 
@@ -140,3 +141,86 @@ func TestCheckAddrConcurrently(t *testing.T) {
 		t.Fatal("Concurrent testing failed")
 	}
 }
+
+func TestCheckAddrContext(t *testing.T) {
+	t.Parallel()
+	c := NewChecker()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	addr, stop := StartTestServer()
+	defer stop()
+
+	checkCtx, checkCancel := context.WithTimeout(ctx, time.Second)
+	defer checkCancel()
+	err := c.CheckAddrContext(checkCtx, addr)
+	assert(t, err == nil)
+}
+
+func TestCheckAddrMode(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(WithMode(ModeConnect))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	addr, stop := StartTestServer()
+	defer stop()
+
+	for _, mode := range []Mode{ModeHalfOpen, ModeConnect, ModeConnectReset} {
+		checkCtx, checkCancel := context.WithTimeout(ctx, time.Second)
+		err := c.CheckAddrMode(checkCtx, addr, mode)
+		checkCancel()
+		if err != nil {
+			t.Fatalf("check in mode %s failed: %v", mode, err)
+		}
+		checkCtx, checkCancel = context.WithTimeout(ctx, time.Second)
+		err = c.CheckAddrMode(checkCtx, AddrDead, mode)
+		checkCancel()
+		assert(t, err != nil)
+	}
+	assert(t, c.CheckAddr(addr, time.Second) == nil)
+}
+
+func TestNetworkMode(t *testing.T) {
+	for _, tc := range []struct {
+		network  string
+		mode     Mode
+		expected Mode
+	}{
+		{"tcp", ModeConnect, ModeConnect},
+		{"tcp", ModeUDP, ModeHalfOpen},
+		{"tcp", ModeSCTP, ModeHalfOpen},
+		{"udp", ModeConnect, ModeUDP},
+		{"sctp", ModeHalfOpen, ModeSCTP},
+	} {
+		mode, err := networkMode(tc.network, tc.mode)
+		if err != nil || mode != tc.expected {
+			t.Errorf("networkMode(%q, %s) = %s, %v; expected %s", tc.network, tc.mode, mode, err, tc.expected)
+		}
+	}
+	var unknown net.UnknownNetworkError
+	if _, err := networkMode("ip", ModeHalfOpen); !errors.As(err, &unknown) {
+		t.Errorf("expected net.UnknownNetworkError, got %v", err)
+	}
+}
+
+func TestCtxErr(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+	assert(t, ctxErr(ctx) == ErrTimeout)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	assert(t, ctxErr(ctx) == context.Canceled)
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+	"github.com/tevino/tcp-shaker/history"
+	"github.com/tevino/tcp-shaker/internal/histogram"
+	"github.com/tevino/tcp-shaker/probe"
+	"github.com/tevino/tcp-shaker/proxy"
+	"github.com/tevino/tcp-shaker/throttle"
+)
+
+// ConcurrentChecker is a wrapper of tcpshaker.Checker with concurrent checking capabilities.
+type ConcurrentChecker struct {
+	conf     *Config
+	logger   *slog.Logger
+	counter  *Counter
+	counters map[string]*Counter
+	latency  map[string]*Latency
+	checker  *tcpshaker.Checker
+	prober   *probe.Checker
+	output   Output
+	queue    chan job
+	closed   chan bool
+	produced chan bool
+	wg       sync.WaitGroup
+}
+
+// job is a check to perform.
+type job struct {
+	target string
+	// scheduledAt is the time the check is scheduled to start in rate
+	// limited mode, zero otherwise.
+	scheduledAt time.Time
+}
+
+// NewConcurrentChecker creates a checker.
+func NewConcurrentChecker(conf *Config, logger *slog.Logger, output Output) *ConcurrentChecker {
+	counters := make(map[string]*Counter, len(conf.Targets))
+	latency := make(map[string]*Latency, len(conf.Targets))
+	for _, target := range conf.Targets {
+		counters[target] = NewCounter(counterIDs...)
+		latency[target] = NewLatency()
+	}
+	var prober *probe.Checker
+	if conf.Probe != nil || conf.Proxy != nil || conf.ProxyHeader != nil {
+		if tlsProbe, ok := conf.Probe.(*probe.TLS); ok {
+			tlsProbe.Report = func(r *probe.TLSReport) { logTLSReport(logger, r) }
+		}
+		var dialer proxy.Dialer = &net.Dialer{}
+		if conf.Proxy != nil {
+			dialer = conf.Proxy
+		}
+		// The address acquired from the limiter is dialed, directly or
+		// through the proxy, see check.
+		opts := []probe.Option{probe.WithProxy(pinnedDialer{dialer})}
+		if conf.ProxyHeader != nil {
+			opts = append(opts, probe.WithProxyHeader(*conf.ProxyHeader))
+		}
+		prober = probe.NewChecker(conf.Probe, opts...)
+	}
+	return &ConcurrentChecker{
+		conf:     conf,
+		prober:   prober,
+		logger:   logger,
+		counter:  NewCounter(counterIDs...),
+		counters: counters,
+		latency:  latency,
+		checker: tcpshaker.NewChecker(
+			tcpshaker.WithLogger(logger),
+			tcpshaker.WithObserver(resolveObserver{}),
+			tcpshaker.WithRawSYN(conf.RawSYN),
+			tcpshaker.WithMode(conf.Mode),
+			tcpshaker.WithUDPPayload(conf.UDPPayload),
+			tcpshaker.WithSYNRetries(conf.SYNRetries),
+			tcpshaker.WithSourcePool(conf.SourcePool),
+		),
+		output:   output,
+		queue:    make(chan job),
+		closed:   make(chan bool),
+		produced: make(chan bool),
+	}
+}
+
+// Count returns the count of given ID of all targets.
+func (cc *ConcurrentChecker) Count(i int) uint64 {
+	return cc.counter.Count(i)
+}
+
+// TargetCount returns the count of given ID of given target.
+func (cc *ConcurrentChecker) TargetCount(target string, i int) uint64 {
+	return cc.counters[target].Count(i)
+}
+
+// TargetLatency returns the latency of succeeded checks of given target.
+func (cc *ConcurrentChecker) TargetLatency(target string) *histogram.Histogram {
+	return cc.latency[target].Snapshot()
+}
+
+// Latency returns the latency of succeeded checks of all targets.
+func (cc *ConcurrentChecker) Latency() *histogram.Histogram {
+	h := newHistogram()
+	for _, target := range cc.conf.Targets {
+		h.Merge(cc.TargetLatency(target))
+	}
+	return h
+}
+
+// Total returns the number of checks to perform, which is the number of
+// checks scheduled so far if -duration is given.
+func (cc *ConcurrentChecker) Total() int {
+	if cc.conf.Duration > 0 {
+		return int(cc.Count(CScheduled))
+	}
+	return cc.conf.Requests * len(cc.conf.Targets)
+}
+
+// TargetTotal is like Total but for given target.
+func (cc *ConcurrentChecker) TargetTotal(target string) int {
+	if cc.conf.Duration > 0 {
+		return int(cc.TargetCount(target, CScheduled))
+	}
+	return cc.conf.Requests
+}
+
+// Start starts the checking loop and waits for it to be ready.
+func (cc *ConcurrentChecker) Start(ctx context.Context) {
+	go func() {
+		err := cc.checker.CheckingLoop(ctx)
+		if err != nil {
+			cc.logger.Error("Error during checking loop", "error", err)
+			os.Exit(ExitInternal)
+		}
+	}()
+
+	cc.logger.Debug("Waiting for checker to be ready")
+	<-cc.checker.WaitReady()
+}
+
+// Launch starts the checker and the workers performing the checks.
+func (cc *ConcurrentChecker) Launch(ctx context.Context) {
+	cc.Start(ctx)
+
+	for i := 0; i < cc.conf.Concurrency; i++ {
+		go cc.worker(ctx)
+	}
+	go cc.produce(ctx)
+}
+
+// produce schedules the checks until -n checks of each target are
+// scheduled or -duration elapsed.
+//
+// With -rate, checks are scheduled at a constant rate regardless of
+// how long the previous checks took, the latency is then measured from the
+// scheduled time to avoid coordinated omission.
+func (cc *ConcurrentChecker) produce(ctx context.Context) {
+	defer close(cc.produced)
+
+	startedAt := time.Now()
+	var deadline <-chan time.Time
+	if cc.conf.Duration > 0 {
+		timer := time.NewTimer(cc.conf.Duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	var interval time.Duration
+	if cc.conf.Rate > 0 {
+		interval = time.Duration(float64(time.Second) / cc.conf.Rate)
+	}
+
+	for n := 0; cc.conf.Duration > 0 || n < cc.conf.Requests*len(cc.conf.Targets); n++ {
+		j := job{target: cc.conf.Targets[n%len(cc.conf.Targets)]}
+		if interval > 0 {
+			j.scheduledAt = startedAt.Add(time.Duration(n) * interval)
+			if wait := time.Until(j.scheduledAt); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-deadline:
+					timer.Stop()
+					return
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				}
+			}
+		}
+
+		cc.wg.Add(1)
+		select {
+		case cc.queue <- j:
+			cc.inc(j.target, CScheduled)
+		case <-deadline:
+			cc.wg.Done()
+			return
+		case <-ctx.Done():
+			cc.wg.Done()
+			return
+		case <-cc.closed:
+			cc.wg.Done()
+			return
+		}
+	}
+}
+
+// Check checks given target once and records the result, nil is returned
+// if ctx is done while waiting for the limiter.
+func (cc *ConcurrentChecker) Check(ctx context.Context, target string) *CheckRecord {
+	return cc.check(ctx, job{target: target})
+}
+
+// throttle waits until the limiter allows a check to target, if any, and
+// returns the address to check, which is the IP the limiter is acquired for
+// so that the target is not resolved again to another one.
+// Unix domain sockets are never throttled.
+func (cc *ConcurrentChecker) throttle(ctx context.Context, target string) (addr string, release func(), err error) {
+	if _, ok := tcpshaker.ParseUnixAddr(target); ok || cc.conf.Limiter == nil {
+		return target, func() {}, nil
+	}
+	ap, err := throttle.Resolve(ctx, target)
+	if err != nil {
+		return "", nil, err
+	}
+	release, err = cc.conf.Limiter.Acquire(ctx, ap)
+	return ap.String(), release, err
+}
+
+func (cc *ConcurrentChecker) check(ctx context.Context, j job) *CheckRecord {
+	target := j.target
+	record := &CheckRecord{Timestamp: j.scheduledAt, Target: target}
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+	throttledAt := time.Now()
+	addr, release, err := cc.throttle(ctx, target)
+	if err != nil && ctx.Err() != nil {
+		// Interrupted before the check is performed.
+		return nil
+	}
+	// Time spent waiting for the limiter is not counted as latency, the delay
+	// from the scheduled time is still counted to avoid coordinated omission.
+	throttled := time.Since(throttledAt)
+	if cc.conf.Limiter != nil {
+		record.ThrottleMS = ms(throttled)
+	}
+	if err == nil {
+		// The check itself is not interrupted, its result is still recorded.
+		checkCtx, cancel := context.WithTimeout(withCheckRecord(context.Background(), record), cc.conf.Timeout)
+		if cc.prober != nil {
+			// The probes see the target, e.g. for SNI, while addr is dialed.
+			err = cc.prober.CheckAddrContext(withPinnedAddr(checkCtx, addr), target)
+		} else {
+			err = cc.checker.CheckAddrContext(checkCtx, addr)
+		}
+		cancel()
+		release()
+	}
+	elapsed := time.Since(record.Timestamp) - throttled
+
+	record.LatencyMS = ms(elapsed)
+	record.Outcome = outcomeOf(err)
+	if err != nil {
+		cc.logger.Debug("Check failed", "addr", target, "error", err)
+		record.Error = err.Error()
+		var errno syscall.Errno
+		if errors.As(err, &errno) {
+			record.Errno = int(errno)
+		}
+	} else {
+		cc.latency[target].Record(elapsed)
+	}
+	if cc.conf.History != nil {
+		cc.conf.History.Record(target, history.NewResult(record.Timestamp, err, elapsed))
+	}
+	cc.inc(target, CRequest)
+	cc.inc(target, outcomeCounters[record.Outcome])
+
+	if err := cc.output.WriteCheck(record); err != nil {
+		cc.logger.Error("Error writing output", "error", err)
+	}
+	return record
+}
+
+// outcomeOf returns the outcome of a check with given error, classified by
+// history.OutcomeOf so that the history agrees with the output.
+func outcomeOf(err error) string {
+	return string(history.OutcomeOf(err))
+}
+
+// logTLSReport logs the result of a TLS handshake at debug level.
+func logTLSReport(logger *slog.Logger, r *probe.TLSReport) {
+	attrs := []any{
+		"server_name", r.ServerName,
+		"handshake_latency", r.HandshakeLatency,
+		"version", r.VersionName(),
+		"cipher_suite", r.CipherSuiteName(),
+		"alpn", r.NegotiatedProtocol,
+	}
+	if !r.NotAfter.IsZero() {
+		attrs = append(attrs, "not_after", r.NotAfter, "expires_in", r.ExpiresIn().Round(time.Hour))
+	}
+	if r.VerifyError != nil {
+		attrs = append(attrs, "verify_error", r.VerifyError)
+	}
+	if r.HostnameError != nil {
+		attrs = append(attrs, "hostname_error", r.HostnameError)
+	}
+	logger.Debug("TLS handshake", attrs...)
+}
+
+type checkRecordKey struct{}
+
+func withCheckRecord(ctx context.Context, record *CheckRecord) context.Context {
+	return context.WithValue(ctx, checkRecordKey{}, record)
+}
+
+// pinnedAddrKey is the context key of the address a check is pinned to.
+type pinnedAddrKey struct{}
+
+// withPinnedAddr returns a copy of ctx making pinnedDialer dial addr.
+func withPinnedAddr(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, pinnedAddrKey{}, addr)
+}
+
+// pinnedDialer dials the address found in ctx, if any, instead of the one given.
+type pinnedDialer struct {
+	dialer proxy.Dialer
+}
+
+func (d pinnedDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if pinned, ok := ctx.Value(pinnedAddrKey{}).(string); ok {
+		addr = pinned
+	}
+	return d.dialer.DialContext(ctx, network, addr)
+}
+
+// resolveObserver fills the resolved IP of the CheckRecord found in ctx.
+type resolveObserver struct {
+	tcpshaker.NopObserver
+}
+
+func (resolveObserver) OnResolved(ctx context.Context, addr *net.TCPAddr) {
+	if record, ok := ctx.Value(checkRecordKey{}).(*CheckRecord); ok && addr != nil {
+		record.IP = addr.IP.String()
+	}
+}
+
+func (cc *ConcurrentChecker) inc(target string, i int) {
+	cc.counter.Inc(i)
+	cc.counters[target].Inc(i)
+}
+
+// Wait returns a chan which is closed when all checks are done.
+func (cc *ConcurrentChecker) Wait() chan bool {
+	c := make(chan bool)
+	go func() {
+		<-cc.produced
+		cc.wg.Wait()
+		close(c)
+	}()
+	return c
+}
+
+// Stop stops the workers.
+func (cc *ConcurrentChecker) Stop() {
+	close(cc.closed)
+}
+
+func (cc *ConcurrentChecker) worker(ctx context.Context) {
+	for {
+		select {
+		case j := <-cc.queue:
+			cc.check(ctx, j)
+			cc.wg.Done()
+		case <-cc.closed:
+			return
+		}
+	}
+}
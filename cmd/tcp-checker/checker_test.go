@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+	"github.com/tevino/tcp-shaker/probe"
+	"github.com/tevino/tcp-shaker/throttle"
+)
+
+func TestOutcomeOf(t *testing.T) {
+	// The errors are described by name, the zero values of some of them
+	// can not be formatted.
+	for _, c := range []struct {
+		name    string
+		err     error
+		outcome string
+	}{
+		{"nil", nil, OutcomeOK},
+		{"ErrTimeout", tcpshaker.ErrTimeout, OutcomeTimeout},
+		{"wrapped ErrTimeout", fmt.Errorf("dial: %w", tcpshaker.ErrTimeout), OutcomeTimeout},
+		{"ErrProbe(ErrTimeout)", &probe.ErrProbe{Probe: "redis", Err: tcpshaker.ErrTimeout}, OutcomeTimeout},
+		{"ErrOpenFiltered", tcpshaker.ErrOpenFiltered, OutcomeOpenFiltered},
+		{"ErrConnect", &tcpshaker.ErrConnect{}, OutcomeConnectError},
+		{"ErrSocketNotFound", tcpshaker.ErrSocketNotFound, OutcomeConnectError},
+		{"ErrSYNDropped", &tcpshaker.ErrSYNDropped{Retransmits: 1}, OutcomeSYNDropped},
+		{"ErrLocalResource", &tcpshaker.ErrLocalResource{}, OutcomeLocalError},
+		{"ErrProbe(ErrUnexpectedResponse)", &probe.ErrProbe{Probe: "redis", Err: probe.ErrUnexpectedResponse}, OutcomeError},
+		{"other", errors.New("other"), OutcomeError},
+	} {
+		if outcome := outcomeOf(c.err); outcome != c.outcome {
+			t.Errorf("outcomeOf(%s) = %s, expected %s", c.name, outcome, c.outcome)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	for _, c := range []struct {
+		counts Counts
+		code   int
+	}{
+		{Counts{Finished: 2, Succeed: 2}, ExitOK},
+		{Counts{Finished: 2, Succeed: 1, OpenFiltered: 1}, ExitOK},
+		{Counts{Finished: 2, Succeed: 1, ErrTimeout: 1}, ExitSomeFailed},
+		{Counts{Finished: 2, ErrConnect: 2}, ExitAllFailed},
+		{Counts{}, ExitAllFailed},
+	} {
+		if code := exitCode(c.counts); code != c.code {
+			t.Errorf("exitCode(%+v) = %d, expected %d", c.counts, code, c.code)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	conf := &Config{Targets: []string{"localhost:80"}, Limiter: throttle.New(throttle.Limits{HostConcurrency: 1})}
+	cc := NewConcurrentChecker(conf, slog.Default(), nil)
+
+	addr, release, err := cc.throttle(context.Background(), "localhost:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if ap, err := netip.ParseAddrPort(addr); err != nil || !ap.Addr().IsLoopback() {
+		t.Fatalf("expected the resolved address of localhost:80, got %q", addr)
+	}
+
+	// The slot is taken, the wait ends with the context.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if record := cc.check(ctx, job{target: "localhost:80"}); record != nil {
+		t.Fatalf("expected no record of an interrupted check, got %+v", record)
+	}
+	if n := cc.Count(CRequest); n != 0 {
+		t.Fatalf("expected no request recorded, got %d", n)
+	}
+}
+
+type recordingDialer struct{ addr string }
+
+func (d *recordingDialer) DialContext(_ context.Context, _, addr string) (net.Conn, error) {
+	d.addr = addr
+	return nil, errors.New("not dialed")
+}
+
+func TestPinnedDialer(t *testing.T) {
+	recorder := &recordingDialer{}
+	dialer := pinnedDialer{recorder}
+
+	_, _ = dialer.DialContext(context.Background(), "tcp", "example.com:443")
+	if recorder.addr != "example.com:443" {
+		t.Fatalf("expected the given address without a pinned one, got %q", recorder.addr)
+	}
+	_, _ = dialer.DialContext(withPinnedAddr(context.Background(), "192.0.2.1:443"), "tcp", "example.com:443")
+	if recorder.addr != "192.0.2.1:443" {
+		t.Fatalf("expected the pinned address, got %q", recorder.addr)
+	}
+}
+
+// fakeDialer fails the dials to the addresses in errs and succeeds the others
+// after delay, it makes the checks go through the probe.Checker without
+// touching the network.
+type fakeDialer struct {
+	errs  map[string]error
+	delay time.Duration
+}
+
+func (d fakeDialer) DialContext(_ context.Context, _, addr string) (net.Conn, error) {
+	time.Sleep(d.delay)
+	if err := d.errs[addr]; err != nil {
+		return nil, err
+	}
+	client, server := net.Pipe()
+	server.Close()
+	return client, nil
+}
+
+// newFakeChecker creates a checker of conf connecting with dialer.
+func newFakeChecker(conf *Config, dialer fakeDialer, output Output) *ConcurrentChecker {
+	conf.Proxy = dialer
+	if conf.Timeout == 0 {
+		conf.Timeout = time.Second
+	}
+	if output == nil {
+		output = newOutput(OutputText, io.Discard)
+	}
+	return NewConcurrentChecker(conf, slog.New(slog.NewTextHandler(io.Discard, nil)), output)
+}
+
+func TestSummaryTargets(t *testing.T) {
+	conf := &Config{Targets: []string{"127.0.0.1:1", "127.0.0.1:2"}, Requests: 2}
+	cc := newFakeChecker(conf, fakeDialer{errs: map[string]error{"127.0.0.1:2": tcpshaker.ErrTimeout}}, nil)
+	for _, target := range conf.Targets {
+		for i := 0; i < conf.Requests; i++ {
+			cc.check(context.Background(), job{target: target})
+		}
+	}
+
+	summary := newSummary(cc, time.Now(), time.Second)
+	expected := []TargetSummary{
+		{Target: "127.0.0.1:1", Counts: Counts{Requests: 2, Finished: 2, Succeed: 2}},
+		{Target: "127.0.0.1:2", Counts: Counts{Requests: 2, Finished: 2, ErrTimeout: 2}},
+	}
+	if len(summary.Targets) != len(expected) {
+		t.Fatalf("expected %d targets, got %+v", len(expected), summary.Targets)
+	}
+	for i, e := range expected {
+		if s := summary.Targets[i]; s.Target != e.Target || s.Counts != e.Counts {
+			t.Errorf("expected %s with %+v, got %s with %+v", e.Target, e.Counts, s.Target, s.Counts)
+		}
+	}
+	if c := summary.Counts; c.Requests != 4 || c.Finished != 4 || c.Succeed != 2 || c.ErrTimeout != 2 {
+		t.Errorf("expected the total of the targets, got %+v", c)
+	}
+}
+
+// recordOutput keeps the records of the checks.
+type recordOutput struct {
+	l       sync.Mutex
+	records []*CheckRecord
+}
+
+func (o *recordOutput) WriteCheck(r *CheckRecord) error {
+	o.l.Lock()
+	o.records = append(o.records, r)
+	o.l.Unlock()
+	return nil
+}
+
+func (o *recordOutput) WriteSummary(*Summary) error { return nil }
+
+// runFor runs the workers and the producer of cc, it fails t if they do not
+// finish within timeout and returns the time they took.
+func runFor(t *testing.T, cc *ConcurrentChecker, timeout time.Duration) time.Duration {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer cc.Stop()
+	startedAt := time.Now()
+	for i := 0; i < cc.conf.Concurrency; i++ {
+		go cc.worker(ctx)
+	}
+	go cc.produce(ctx)
+	select {
+	case <-cc.Wait():
+	case <-time.After(timeout):
+		t.Fatalf("expected the checks to finish within %s", timeout)
+	}
+	return time.Since(startedAt)
+}
+
+func TestProduceRate(t *testing.T) {
+	const rate, duration = 100, 300 * time.Millisecond
+	interval := time.Second / rate
+	output := &recordOutput{}
+	conf := &Config{Targets: []string{"127.0.0.1:1", "127.0.0.1:2"}, Concurrency: 4, Rate: rate, Duration: duration}
+	cc := newFakeChecker(conf, fakeDialer{}, output)
+
+	elapsed := runFor(t, cc, duration+time.Second)
+	if elapsed < duration || elapsed > duration+200*time.Millisecond {
+		t.Errorf("expected -duration %s to end the run, took %s", duration, elapsed)
+	}
+	// Tolerate a slow start, the checks scheduled are never more than the duration allows.
+	expected := int(duration / interval)
+	if n := len(output.records); n < expected/2 || n > expected {
+		t.Fatalf("expected about %d checks, got %d", expected, n)
+	}
+	if scheduled := cc.Total(); scheduled != len(output.records) {
+		t.Errorf("expected all the %d scheduled checks to be done, got %d", scheduled, len(output.records))
+	}
+	// The checks start at a constant rate regardless of how long each took.
+	slices.SortFunc(output.records, func(a, b *CheckRecord) int { return a.Timestamp.Compare(b.Timestamp) })
+	for i, r := range output.records[1:] {
+		if gap := r.Timestamp.Sub(output.records[i].Timestamp); gap != interval {
+			t.Fatalf("expected the checks scheduled every %s, got %s between %d and %d", interval, gap, i, i+1)
+		}
+		if r.Target != conf.Targets[(i+1)%len(conf.Targets)] {
+			t.Fatalf("expected the targets checked in turn, got %s at %d", r.Target, i+1)
+		}
+	}
+}
+
+func TestProduceCoordinatedOmission(t *testing.T) {
+	// A single worker takes 3 intervals per check, the checks waiting for
+	// it are late and their latency includes the delay.
+	const rate, delay, duration = 100, 30 * time.Millisecond, 300 * time.Millisecond
+	output := &recordOutput{}
+	conf := &Config{Targets: []string{"127.0.0.1:1"}, Concurrency: 1, Rate: rate, Duration: duration}
+	cc := newFakeChecker(conf, fakeDialer{delay: delay}, output)
+
+	elapsed := runFor(t, cc, duration+time.Second)
+	// The run ends at -duration and the check in progress is waited for.
+	if elapsed < duration || elapsed > duration+delay+200*time.Millisecond {
+		t.Errorf("expected -duration %s to end the run, took %s", duration, elapsed)
+	}
+	records := output.records
+	if n := len(records); n < 2 || n > int(duration/delay)+1 {
+		t.Fatalf("expected about %d checks limited by the worker, got %d", duration/delay, n)
+	}
+	for i, r := range records {
+		if i > 0 && r.Timestamp.Sub(records[i-1].Timestamp) != time.Second/rate {
+			t.Fatalf("expected check %d scheduled an interval after the previous one, got %s", i, r.Timestamp.Sub(records[i-1].Timestamp))
+		}
+		// Check i waits for the i checks before it, taking delay each, and
+		// is scheduled i intervals later.
+		minLatency := time.Duration(i+1)*delay - time.Duration(i)*time.Second/rate
+		if latency := time.Duration(r.LatencyMS * float64(time.Millisecond)); latency < minLatency {
+			t.Errorf("expected the latency of check %d to include its delay of at least %s, got %s", i, minLatency, latency)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+)
+
+func TestParseConfigModes(t *testing.T) {
+	for _, c := range []struct {
+		args []string
+		mode tcpshaker.Mode
+		err  string
+	}{
+		{[]string{"-mode", "udp"}, tcpshaker.ModeUDP, ""},
+		{[]string{"-probe", "redis"}, tcpshaker.ModeHalfOpen, ""},
+		{[]string{"-probe", "redis", "-mode", "connect"}, tcpshaker.ModeConnect, ""},
+		{[]string{"-probe", "redis", "-mode", "udp"}, 0, "-mode udp is not supported"},
+		{[]string{"-proxy", "socks5://127.0.0.1:1080", "-mode", "half-open"}, 0, "-mode half-open is not supported"},
+		{[]string{"-proxy-protocol", "2", "-mode", "sctp"}, 0, "-mode sctp is not supported"},
+		{[]string{"-probe", "redis", "-raw-syn"}, 0, "-raw-syn is not supported"},
+		{[]string{"-raw-syn", "-mode", "connect"}, 0, "-raw-syn is not supported"},
+		{[]string{"-mode", "nope"}, 0, "invalid mode"},
+	} {
+		conf, err := parseConfig("tcp-checker", append(c.args, "127.0.0.1:80"), nil)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%v: expected error %q, got %v", c.args, c.err, err)
+			}
+			continue
+		}
+		if err != nil || conf.Mode != c.mode {
+			t.Errorf("%v: expected mode %s, got %v", c.args, c.mode, err)
+		}
+	}
+}
+
+func TestCollectTargets(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "targets")
+	content := "# web servers\n10.0.0.1:80\n\n  10.0.0.2:80  \n\t# commented\n10.0.0.1:80\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name    string
+		addrs   []string
+		files   []string
+		args    []string
+		stdin   string
+		targets []string
+	}{
+		{"repeated -a", []string{"10.0.0.1:80", "10.0.0.3:22", "10.0.0.1:80"}, nil, nil, "", []string{"10.0.0.1:80", "10.0.0.3:22"}},
+		{"positional args", nil, nil, []string{"10.0.0.3:22", "10.0.0.4:22"}, "", []string{"10.0.0.3:22", "10.0.0.4:22"}},
+		{"-f", nil, []string{file}, nil, "", []string{"10.0.0.1:80", "10.0.0.2:80"}},
+		{"-f -", nil, []string{"-"}, nil, "10.0.0.5:53\n# dns\n\n", []string{"10.0.0.5:53"}},
+		{"positional -", nil, nil, []string{"-"}, "10.0.0.5:53\n", []string{"10.0.0.5:53"}},
+		{"stdin read once", nil, []string{"-"}, []string{"-"}, "10.0.0.5:53\n", []string{"10.0.0.5:53"}},
+		{
+			"in order of -a, -f and args",
+			[]string{"10.0.0.3:22"}, []string{file}, []string{"10.0.0.2:80", "-", "10.0.0.4:22"}, "10.0.0.5:53\n",
+			[]string{"10.0.0.3:22", "10.0.0.1:80", "10.0.0.2:80", "10.0.0.5:53", "10.0.0.4:22"},
+		},
+	} {
+		targets, err := collectTargets(c.addrs, c.files, c.args, strings.NewReader(c.stdin))
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !slices.Equal(targets, c.targets) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.targets, targets)
+		}
+	}
+
+	if _, err := collectTargets(nil, []string{filepath.Join(dir, "missing")}, nil, nil); err == nil {
+		t.Error("expected an error of a missing file")
+	}
+}
+
+func TestReadTargets(t *testing.T) {
+	for _, c := range []struct {
+		input   string
+		targets []string
+	}{
+		{"", nil},
+		{"\n\n", nil},
+		{"# only a comment\n", nil},
+		{"10.0.0.1:80", []string{"10.0.0.1:80"}},
+		{"10.0.0.1:80\r\n 10.0.0.2:80 \n#10.0.0.3:80\n", []string{"10.0.0.1:80", "10.0.0.2:80"}},
+		{"10.0.0.1:80\n10.0.0.1:80\n", []string{"10.0.0.1:80", "10.0.0.1:80"}},
+	} {
+		targets, err := readTargets(strings.NewReader(c.input))
+		if err != nil || !slices.Equal(targets, c.targets) {
+			t.Errorf("readTargets(%q) = %v, %v, expected %v", c.input, targets, err, c.targets)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import "sync/atomic"
+
+// Counter is an atomic counter for multiple metrics.
+type Counter struct {
+	counters map[int]*uint64
+}
+
+// NewCounter creates Counter with given IDs.
+func NewCounter(ids ...int) *Counter {
+	counter := &Counter{}
+	counter.Declare(ids...)
+	return counter
+}
+
+// Declare declares the ID of counters.
+// NOTE: This must be called before counting, and should only be called once.
+func (c *Counter) Declare(ids ...int) {
+	c.counters = make(map[int]*uint64, len(ids))
+	for _, id := range ids {
+		var i uint64
+		c.counters[id] = &i
+	}
+}
+
+// Inc increases the counter of given ID by one and returns the new value.
+func (c *Counter) Inc(i int) uint64 {
+	return atomic.AddUint64(c.counters[i], 1)
+}
+
+// Count returns the value of counter with given ID.
+func (c *Counter) Count(i int) uint64 {
+	return atomic.LoadUint64(c.counters[i])
+}
+
+// Available counter names.
+const (
+	CRequest int = iota
+	CSucceed
+	CErrConnect
+	CErrTimeout
+	CErrOther
+	CScheduled
+	CSYNDropped
+	CErrLocal
+	COpenFiltered
+)
+
+// counterIDs contains all available counter names.
+var counterIDs = []int{CRequest, CSucceed, CErrConnect, CErrTimeout, CErrOther, CScheduled, CSYNDropped, CErrLocal, COpenFiltered}
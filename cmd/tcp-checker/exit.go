@@ -0,0 +1,37 @@
+package main
+
+// Exit codes of tcp-checker.
+const (
+	// ExitOK indicates all the finished checks succeeded.
+	ExitOK = 0
+	// ExitSomeFailed indicates some of the checks failed.
+	ExitSomeFailed = 1
+	// ExitAllFailed indicates all the checks failed or none of them finished.
+	ExitAllFailed = 2
+	// ExitUsage indicates invalid arguments.
+	ExitUsage = 3
+	// ExitInternal indicates an internal error e.g. the checking loop failed.
+	ExitInternal = 4
+)
+
+const exitCodesUsage = `
+Exit codes:
+  0  all finished checks succeeded
+  1  some checks failed
+  2  all checks failed or none of them finished
+  3  usage error
+  4  internal error
+`
+
+// exitCode returns the exit code for given counts.
+func exitCode(c Counts) int {
+	passed := c.Passed()
+	switch {
+	case c.Finished > 0 && passed == c.Finished:
+		return ExitOK
+	case passed > 0:
+		return ExitSomeFailed
+	default:
+		return ExitAllFailed
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tevino/tcp-shaker/internal/histogram"
+)
+
+// maxLatency is the highest latency could be tracked accurately.
+const maxLatency = time.Hour
+
+// latencyPercentiles are the percentiles reported.
+var latencyPercentiles = []float64{50, 90, 99, 99.9}
+
+// Latency is a histogram of latencies safe for concurrent use.
+type Latency struct {
+	l sync.Mutex
+	h *histogram.Histogram
+}
+
+// NewLatency creates a Latency.
+func NewLatency() *Latency {
+	return &Latency{h: newHistogram()}
+}
+
+func newHistogram() *histogram.Histogram {
+	return histogram.New(maxLatency.Microseconds())
+}
+
+// Record records the latency d with microsecond precision.
+func (l *Latency) Record(d time.Duration) {
+	l.l.Lock()
+	l.h.Record(d.Microseconds())
+	l.l.Unlock()
+}
+
+// Snapshot returns a copy of the recorded latencies.
+func (l *Latency) Snapshot() *histogram.Histogram {
+	h := newHistogram()
+	l.l.Lock()
+	h.Merge(l.h)
+	l.l.Unlock()
+	return h
+}
+
+func us(v int64) time.Duration {
+	return time.Duration(v) * time.Microsecond
+}
+
+func usf(v float64) time.Duration {
+	return time.Duration(v * float64(time.Microsecond))
+}
+
+// latencyAttrs returns the latency summary as log attributes.
+func latencyAttrs(s *LatencySummary) []any {
+	if s == nil {
+		return nil
+	}
+	attrs := []any{
+		"latency_min", msd(s.Min),
+		"latency_mean", msd(s.Mean),
+		"latency_max", msd(s.Max),
+		"latency_stddev", msd(s.StdDev),
+	}
+	for _, p := range latencyPercentiles {
+		key := "p" + fmt.Sprint(p)
+		attrs = append(attrs, "latency_"+key, msd(s.Percentiles[key]))
+	}
+	return attrs
+}
+
+func msd(v float64) time.Duration {
+	return time.Duration(v * float64(time.Millisecond)).Round(time.Microsecond)
+}
+
+// histogramBars is the number of bars in the latency histogram.
+const histogramBars = 10
+
+// histogramWidth is the width of the longest bar in the latency histogram.
+const histogramWidth = 40
+
+// writeLatencyReport writes the summary, percentiles and an ASCII histogram of h to w.
+func writeLatencyReport(w io.Writer, h *histogram.Histogram) {
+	if h.Count() == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Latency of %d succeeded checks:\n", h.Count())
+	fmt.Fprintf(w, "  %-8s %-12s %-12s %-12s\n", "min", "mean", "max", "stddev")
+	fmt.Fprintf(w, "  %-8s %-12s %-12s %-12s\n", us(h.Min()), usf(h.Mean()).Round(time.Microsecond), us(h.Max()), usf(h.StdDev()).Round(time.Microsecond))
+
+	fmt.Fprintln(w, "\nPercentiles:")
+	for _, p := range latencyPercentiles {
+		fmt.Fprintf(w, "  %6s%%  %s\n", fmt.Sprint(p), us(h.ValueAtPercentile(p)))
+	}
+
+	fmt.Fprintln(w, "\nHistogram:")
+	bars := h.Distribution(histogramBars)
+	var highest uint64
+	for _, bar := range bars {
+		highest = max(highest, bar.Count)
+	}
+	for _, bar := range bars {
+		width := int(bar.Count * histogramWidth / highest)
+		if bar.Count > 0 && width == 0 {
+			width = 1
+		}
+		fmt.Fprintf(w, "  %12s [%d]\t|%s\n", us(bar.To), bar.Count, strings.Repeat("■", width))
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tevino/tcp-shaker/history"
+	"github.com/tevino/tcp-shaker/internal/histogram"
+	"github.com/tevino/tcp-shaker/throttle"
+)
+
+// Available output formats.
+const (
+	OutputText   = "text"
+	OutputJSON   = "json"
+	OutputNDJSON = "ndjson"
+	OutputCSV    = "csv"
+)
+
+var outputFormats = []string{OutputText, OutputJSON, OutputNDJSON, OutputCSV}
+
+// Outcomes of a check, see history.OutcomeOf.
+const (
+	OutcomeOK           = string(history.OutcomeOK)
+	OutcomeConnectError = string(history.OutcomeConnectError)
+	OutcomeTimeout      = string(history.OutcomeTimeout)
+	OutcomeError        = string(history.OutcomeError)
+	// OutcomeSYNDropped indicates an overloaded listener, see tcpshaker.ErrSYNDropped.
+	OutcomeSYNDropped = string(history.OutcomeSYNDropped)
+	// OutcomeLocalError indicates a local resource is exhausted, see tcpshaker.ErrLocalResource.
+	OutcomeLocalError = string(history.OutcomeLocalError)
+	// OutcomeOpenFiltered indicates no response from a UDP port, which is
+	// open or filtered, see tcpshaker.ErrOpenFiltered.
+	OutcomeOpenFiltered = string(history.OutcomeOpenFiltered)
+)
+
+// outcomeCounters maps the outcomes to their counter IDs.
+var outcomeCounters = map[string]int{
+	OutcomeOK:           CSucceed,
+	OutcomeConnectError: CErrConnect,
+	OutcomeTimeout:      CErrTimeout,
+	OutcomeError:        CErrOther,
+	OutcomeSYNDropped:   CSYNDropped,
+	OutcomeLocalError:   CErrLocal,
+	OutcomeOpenFiltered: COpenFiltered,
+}
+
+// CheckRecord is the result of a single check.
+type CheckRecord struct {
+	Type      string    `json:"type,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Target    string    `json:"target"`
+	IP        string    `json:"ip,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Errno     int       `json:"errno,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMS float64   `json:"latency_ms"`
+	// ThrottleMS is the time spent waiting for the per-destination limits,
+	// which is not counted in LatencyMS.
+	ThrottleMS float64 `json:"throttle_ms,omitempty"`
+}
+
+// Counts contains the counters of checks.
+type Counts struct {
+	Requests   int    `json:"requests"`
+	Finished   uint64 `json:"finished"`
+	Succeed    uint64 `json:"succeed"`
+	ErrConnect uint64 `json:"err_connect"`
+	ErrTimeout uint64 `json:"err_timeout"`
+	ErrOther   uint64 `json:"err_other"`
+	SYNDropped uint64 `json:"syn_dropped,omitempty"`
+	ErrLocal   uint64 `json:"err_local,omitempty"`
+	// OpenFiltered is not counted as failed, see OutcomeOpenFiltered.
+	OpenFiltered uint64 `json:"open_filtered,omitempty"`
+}
+
+// Passed returns the number of checks not failed, which includes the ports
+// without a UDP response, see OutcomeOpenFiltered.
+func (c Counts) Passed() uint64 {
+	return c.Succeed + c.OpenFiltered
+}
+
+func newCounts(requests int, count func(int) uint64) Counts {
+	return Counts{
+		Requests:     requests,
+		Finished:     count(CRequest),
+		Succeed:      count(CSucceed),
+		ErrConnect:   count(CErrConnect),
+		ErrTimeout:   count(CErrTimeout),
+		ErrOther:     count(CErrOther),
+		SYNDropped:   count(CSYNDropped),
+		ErrLocal:     count(CErrLocal),
+		OpenFiltered: count(COpenFiltered),
+	}
+}
+
+// LatencySummary is the summary of latencies of succeeded checks in milliseconds.
+type LatencySummary struct {
+	Min         float64            `json:"min_ms"`
+	Mean        float64            `json:"mean_ms"`
+	Max         float64            `json:"max_ms"`
+	StdDev      float64            `json:"stddev_ms"`
+	Percentiles map[string]float64 `json:"percentiles_ms"`
+}
+
+func newLatencySummary(h *histogram.Histogram) *LatencySummary {
+	if h.Count() == 0 {
+		return nil
+	}
+	s := &LatencySummary{
+		Min:         ms(us(h.Min())),
+		Mean:        ms(usf(h.Mean())),
+		Max:         ms(us(h.Max())),
+		StdDev:      ms(usf(h.StdDev())),
+		Percentiles: make(map[string]float64, len(latencyPercentiles)),
+	}
+	for _, p := range latencyPercentiles {
+		s.Percentiles["p"+fmt.Sprint(p)] = ms(us(h.ValueAtPercentile(p)))
+	}
+	return s
+}
+
+// TargetSummary is the summary of checks of a target.
+type TargetSummary struct {
+	Target string `json:"target"`
+	Counts
+	Latency *LatencySummary `json:"latency,omitempty"`
+}
+
+// Summary is the summary of all checks.
+type Summary struct {
+	Type       string    `json:"type,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS float64   `json:"duration_ms"`
+	Counts
+	Latency  *LatencySummary  `json:"latency,omitempty"`
+	Throttle *ThrottleSummary `json:"throttle,omitempty"`
+	Targets  []TargetSummary  `json:"targets"`
+
+	latency *histogram.Histogram
+}
+
+// ThrottleSummary contains the delays caused by per-destination limits.
+type ThrottleSummary struct {
+	Acquired    uint64  `json:"acquired"`
+	Throttled   uint64  `json:"throttled"`
+	MeanDelayMS float64 `json:"mean_delay_ms"`
+	MaxDelayMS  float64 `json:"max_delay_ms"`
+}
+
+// newThrottleSummary returns nil if limiter is nil.
+func newThrottleSummary(limiter *throttle.Limiter) *ThrottleSummary {
+	if limiter == nil {
+		return nil
+	}
+	stats := limiter.Stats()
+	return &ThrottleSummary{
+		Acquired:    stats.Acquired,
+		Throttled:   stats.Throttled,
+		MeanDelayMS: ms(stats.MeanDelay()),
+		MaxDelayMS:  ms(stats.MaxDelay),
+	}
+}
+
+// throttleAttrs returns the throttle summary as log attributes.
+func throttleAttrs(s *ThrottleSummary) []any {
+	if s == nil {
+		return nil
+	}
+	return []any{
+		"throttled", s.Throttled,
+		"throttle_delay_mean", msd(s.MeanDelayMS),
+		"throttle_delay_max", msd(s.MaxDelayMS),
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Output writes the results of checks in a specific format.
+// NOTE: WriteCheck could be called concurrently.
+type Output interface {
+	WriteCheck(*CheckRecord) error
+	WriteSummary(*Summary) error
+}
+
+// newOutput creates an Output of given format writing to w.
+func newOutput(format string, w io.Writer) Output {
+	switch format {
+	case OutputJSON:
+		return &jsonOutput{w: w}
+	case OutputNDJSON:
+		return &ndjsonOutput{enc: json.NewEncoder(w)}
+	case OutputCSV:
+		return &csvOutput{w: csv.NewWriter(w)}
+	}
+	return &textOutput{w: w}
+}
+
+// textOutput writes a human readable latency report.
+type textOutput struct {
+	w io.Writer
+}
+
+func (o *textOutput) WriteCheck(*CheckRecord) error { return nil }
+
+func (o *textOutput) WriteSummary(s *Summary) error {
+	writeLatencyReport(o.w, s.latency)
+	return nil
+}
+
+// jsonOutput writes a single JSON document containing all the checks and the summary.
+type jsonOutput struct {
+	l      sync.Mutex
+	w      io.Writer
+	checks []*CheckRecord
+}
+
+func (o *jsonOutput) WriteCheck(r *CheckRecord) error {
+	o.l.Lock()
+	o.checks = append(o.checks, r)
+	o.l.Unlock()
+	return nil
+}
+
+func (o *jsonOutput) WriteSummary(s *Summary) error {
+	o.l.Lock()
+	defer o.l.Unlock()
+	checks := o.checks
+	if checks == nil {
+		checks = []*CheckRecord{}
+	}
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Checks  []*CheckRecord `json:"checks"`
+		Summary *Summary       `json:"summary"`
+	}{checks, s})
+}
+
+// ndjsonOutput writes a JSON object per line for every check as soon as it
+// is done, followed by the summary, they are distinguished by "type".
+type ndjsonOutput struct {
+	l   sync.Mutex
+	enc *json.Encoder
+}
+
+func (o *ndjsonOutput) WriteCheck(r *CheckRecord) error {
+	rec := *r
+	rec.Type = "check"
+	o.l.Lock()
+	defer o.l.Unlock()
+	return o.enc.Encode(&rec)
+}
+
+func (o *ndjsonOutput) WriteSummary(s *Summary) error {
+	sum := *s
+	sum.Type = "summary"
+	o.l.Lock()
+	defer o.l.Unlock()
+	return o.enc.Encode(&sum)
+}
+
+// csvOutput writes a row for every check, the summary is not included.
+type csvOutput struct {
+	l           sync.Mutex
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+var csvHeader = []string{"timestamp", "target", "ip", "outcome", "errno", "error", "latency_ms"}
+
+func (o *csvOutput) WriteCheck(r *CheckRecord) error {
+	o.l.Lock()
+	defer o.l.Unlock()
+	o.writeHeader()
+	errno := ""
+	if r.Errno != 0 {
+		errno = strconv.Itoa(r.Errno)
+	}
+	err := o.w.Write([]string{
+		r.Timestamp.Format(time.RFC3339Nano),
+		r.Target,
+		r.IP,
+		r.Outcome,
+		errno,
+		r.Error,
+		strconv.FormatFloat(r.LatencyMS, 'f', 3, 64),
+	})
+	if err != nil {
+		return err
+	}
+	o.w.Flush()
+	return o.w.Error()
+}
+
+func (o *csvOutput) WriteSummary(*Summary) error {
+	o.l.Lock()
+	defer o.l.Unlock()
+	o.writeHeader()
+	o.w.Flush()
+	return o.w.Error()
+}
+
+func (o *csvOutput) writeHeader() {
+	if !o.wroteHeader {
+		o.wroteHeader = true
+		_ = o.w.Write(csvHeader)
+	}
+}
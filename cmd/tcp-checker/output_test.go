@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+)
+
+const (
+	okTarget      = "127.0.0.1:1"
+	refusedTarget = "127.0.0.1:2"
+	timeoutTarget = "127.0.0.1:3"
+)
+
+var outputErrs = map[string]error{
+	refusedTarget: syscall.ECONNREFUSED,
+	timeoutTarget: tcpshaker.ErrTimeout,
+}
+
+// runOutput checks every target of conf once in order with outputErrs and
+// writes the checks and the summary in given format.
+func runOutput(t *testing.T, format string, conf *Config) (*bytes.Buffer, *Summary) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	output := newOutput(format, buf)
+	cc := newFakeChecker(conf, fakeDialer{errs: outputErrs}, output)
+	startedAt := time.Now()
+	for _, target := range conf.Targets {
+		cc.check(context.Background(), job{target: target})
+	}
+	summary := newSummary(cc, startedAt, time.Since(startedAt))
+	if err := output.WriteSummary(summary); err != nil {
+		t.Fatal(err)
+	}
+	return buf, summary
+}
+
+func newOutputConfig() *Config {
+	return &Config{Targets: []string{okTarget, refusedTarget, timeoutTarget}, Requests: 1}
+}
+
+// checkRecords checks the records of the checks done by runOutput.
+func checkRecords(t *testing.T, records []CheckRecord) {
+	t.Helper()
+	if len(records) != 3 {
+		t.Fatalf("expected 3 checks, got %+v", records)
+	}
+	for i, e := range []struct {
+		target  string
+		outcome string
+		errno   int
+	}{
+		{okTarget, OutcomeOK, 0},
+		{refusedTarget, OutcomeConnectError, int(syscall.ECONNREFUSED)},
+		{timeoutTarget, OutcomeTimeout, 0},
+	} {
+		r := records[i]
+		if r.Target != e.target || r.Outcome != e.outcome || r.Errno != e.errno || r.Timestamp.IsZero() {
+			t.Errorf("expected %s %s with errno %d, got %+v", e.target, e.outcome, e.errno, r)
+		}
+		if (r.Outcome == OutcomeOK) != (r.Error == "") {
+			t.Errorf("expected an error only for a failed check, got %+v", r)
+		}
+	}
+}
+
+// checkSummary checks the summary of the checks done by runOutput.
+func checkSummary(t *testing.T, s *Summary) {
+	t.Helper()
+	expected := Counts{Requests: 3, Finished: 3, Succeed: 1, ErrConnect: 1, ErrTimeout: 1}
+	if s.Counts != expected {
+		t.Errorf("expected counts %+v, got %+v", expected, s.Counts)
+	}
+	if s.Latency == nil || s.StartedAt.IsZero() {
+		t.Errorf("expected the latency and start time of the checks, got %+v", s)
+	}
+	if len(s.Targets) != 3 || s.Targets[0].Target != okTarget || s.Targets[0].Succeed != 1 || s.Targets[1].ErrConnect != 1 || s.Targets[2].ErrTimeout != 1 {
+		t.Errorf("expected the counts of every target, got %+v", s.Targets)
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	buf, _ := runOutput(t, OutputJSON, newOutputConfig())
+	var doc struct {
+		Checks  []CheckRecord `json:"checks"`
+		Summary *Summary      `json:"summary"`
+	}
+	dec := json.NewDecoder(buf)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, doc.Checks)
+	if doc.Summary == nil {
+		t.Fatal("expected a summary")
+	}
+	checkSummary(t, doc.Summary)
+	if dec.More() {
+		t.Error("expected a single document")
+	}
+}
+
+func TestJSONOutputWithoutChecks(t *testing.T) {
+	buf, _ := runOutput(t, OutputJSON, &Config{})
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if string(doc["checks"]) != "[]" {
+		t.Errorf("expected an empty array of checks, got %s", doc["checks"])
+	}
+}
+
+func TestNDJSONOutput(t *testing.T) {
+	buf, _ := runOutput(t, OutputNDJSON, newOutputConfig())
+	var records []CheckRecord
+	var summaries []*Summary
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var line struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("%q: %v", scanner.Text(), err)
+		}
+		switch line.Type {
+		case "check":
+			if len(summaries) > 0 {
+				t.Fatalf("expected no check after the summary, got %q", scanner.Text())
+			}
+			var r CheckRecord
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, r)
+		case "summary":
+			var s Summary
+			if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+				t.Fatal(err)
+			}
+			summaries = append(summaries, &s)
+		default:
+			t.Fatalf("unexpected line %q", scanner.Text())
+		}
+	}
+	checkRecords(t, records)
+	if len(summaries) != 1 {
+		t.Fatalf("expected a summary, got %d", len(summaries))
+	}
+	checkSummary(t, summaries[0])
+}
+
+func TestCSVOutput(t *testing.T) {
+	buf, _ := runOutput(t, OutputCSV, newOutputConfig())
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) == 0 || !slices.Equal(rows[0], csvHeader) {
+		t.Fatalf("expected the header %v, got %v", csvHeader, rows)
+	}
+	var records []CheckRecord
+	for _, row := range rows[1:] {
+		var r CheckRecord
+		if err := decodeCSVRow(row, &r); err != nil {
+			t.Fatalf("%v: %v", row, err)
+		}
+		records = append(records, r)
+	}
+	checkRecords(t, records)
+}
+
+func TestCSVOutputWithoutChecks(t *testing.T) {
+	buf, _ := runOutput(t, OutputCSV, &Config{})
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil || len(rows) != 1 || !slices.Equal(rows[0], csvHeader) {
+		t.Fatalf("expected only the header, got %v, %v", rows, err)
+	}
+}
+
+// decodeCSVRow decodes a row of csvOutput into r.
+func decodeCSVRow(row []string, r *CheckRecord) error {
+	if len(row) != len(csvHeader) {
+		return csv.ErrFieldCount
+	}
+	var err error
+	if r.Timestamp, err = time.Parse(time.RFC3339Nano, row[0]); err != nil {
+		return err
+	}
+	r.Target, r.IP, r.Outcome, r.Error = row[1], row[2], row[3], row[5]
+	if row[4] != "" {
+		if err := json.Unmarshal([]byte(row[4]), &r.Errno); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal([]byte(row[6]), &r.LatencyMS)
+}
+
+func TestRunExitCode(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		targets []string
+		code    int
+	}{
+		{"all succeeded", []string{okTarget}, ExitOK},
+		{"some failed", []string{okTarget, refusedTarget}, ExitSomeFailed},
+		{"all failed", []string{refusedTarget, timeoutTarget}, ExitAllFailed},
+		{"none finished", nil, ExitAllFailed},
+	} {
+		_, summary := runOutput(t, OutputText, &Config{Targets: c.targets, Requests: 1})
+		if code := exitCode(summary.Counts); code != c.code {
+			t.Errorf("%s: expected exit code %d, got %d", c.name, c.code, code)
+		}
+	}
+
+	_, err := parseConfig("tcp-checker", []string{"-h"}, nil)
+	if code := usageExitCode(err); code != ExitOK {
+		t.Errorf("-h: expected exit code %d, got %d", ExitOK, code)
+	}
+	for _, args := range [][]string{
+		{},
+		{"-n", "0", okTarget},
+		{"-undefined", okTarget},
+	} {
+		_, err := parseConfig("tcp-checker", args, nil)
+		if err == nil {
+			t.Errorf("%v: expected an error", args)
+			continue
+		}
+		if code := usageExitCode(err); code != ExitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, ExitUsage, code)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/tevino/tcp-shaker/capture"
+	"github.com/tevino/tcp-shaker/history"
+	"github.com/tevino/tcp-shaker/internal/histogram"
+)
+
+// runPing probes the target at an interval like ping and returns the exit code.
+func runPing(name string, args []string) int {
+	conf, err := parsePingConfig(name, args)
+	if err != nil {
+		return usageExitCode(err)
+	}
+	logger := newLogger(conf.LogFormat, conf.LogLevel)
+	target := conf.Targets[0]
+
+	checker := NewConcurrentChecker(conf, logger, newOutput(OutputText, io.Discard))
+	defer checker.Stop()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	checker.Start(ctx)
+
+	w := os.Stdout
+	fmt.Fprintf(w, "TCPING %s, interval %s, timeout %s\n", target, conf.Interval, conf.Timeout)
+	startedAt := time.Now()
+	ticker := time.NewTicker(conf.Interval)
+	defer ticker.Stop()
+
+probing:
+	for seq := 0; conf.Requests == 0 || seq < conf.Requests; seq++ {
+		if seq > 0 {
+			select {
+			case <-ctx.Done():
+				break probing
+			case <-ticker.C:
+			}
+		}
+		if conf.Capture {
+			if err := captureProbe(ctx, w, seq, checker, target); err != nil {
+				logger.Error("Error capturing packets", "error", err)
+				return ExitInternal
+			}
+		} else {
+			record := checker.Check(ctx, target)
+			if record == nil {
+				break probing
+			}
+			writeProbe(w, seq, record)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	counts := newCounts(int(checker.Count(CRequest)), checker.Count)
+	writePingSummary(w, target, counts, checker.Latency(), time.Since(startedAt))
+	if conf.History != nil {
+		writeAvailability(w, conf.History.Summary(target))
+		if err := writeHistory(conf); err != nil {
+			logger.Error("Error writing history", "error", err)
+			return ExitInternal
+		}
+	}
+	return exitCode(counts)
+}
+
+// writeProbe writes the result of a probe in a line.
+func writeProbe(w io.Writer, seq int, r *CheckRecord) {
+	if r.Outcome == OutcomeOK {
+		fmt.Fprintf(w, "from %s: seq=%d time=%.3f ms\n", r.IP, seq, r.LatencyMS)
+		return
+	}
+	from := r.Target
+	if r.IP != "" {
+		from = r.IP
+	}
+	fmt.Fprintf(w, "from %s: seq=%d %s: %s\n", from, seq, r.Outcome, r.Error)
+}
+
+// writeAvailability writes the availability of the target within every window of the history.
+func writeAvailability(w io.Writer, stats []history.Stats) {
+	for _, s := range stats {
+		fmt.Fprintf(w, "availability %s = %.2f%% (%d probes, %d flaps, p99 %.3f ms)",
+			s.Window, s.Availability*100, s.Results, s.Flaps, ms(s.P99))
+		if s.Truncated {
+			fmt.Fprint(w, ", truncated by -history-size")
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// captureGrace is the time to wait for the last packets of a probe,
+// it is longer than the delayed ACK timeout of Linux(40ms).
+const captureGrace = 100 * time.Millisecond
+
+// captureProbe probes the target while capturing its packets and writes both,
+// nothing is written if ctx is done before the probe.
+func captureProbe(ctx context.Context, w io.Writer, seq int, checker *ConcurrentChecker, target string) error {
+	addr, err := net.ResolveTCPAddr("tcp", target)
+	if err != nil {
+		return err
+	}
+	c, err := capture.Start(uint16(addr.Port))
+	if err != nil {
+		return err
+	}
+	record := checker.Check(ctx, target)
+	time.Sleep(captureGrace)
+	packets := capture.Handshake(c.Stop(), uint16(addr.Port))
+	if record == nil {
+		return nil
+	}
+
+	writeProbe(w, seq, record)
+	for _, p := range packets {
+		fmt.Fprintf(w, "  %s\n", p)
+	}
+	switch {
+	case capture.Completed(packets):
+		fmt.Fprintln(w, "  handshake completed: the SYN-ACK was acknowledged")
+	case capture.Aborted(packets):
+		fmt.Fprintln(w, "  handshake aborted: no ACK was sent")
+	}
+	return nil
+}
+
+// writePingSummary writes the loss percentage and round-trip statistics,
+// the probes passed as in exitCode are not lost.
+func writePingSummary(w io.Writer, target string, c Counts, latency *histogram.Histogram, duration time.Duration) {
+	var loss float64
+	if c.Finished > 0 {
+		loss = float64(c.Finished-c.Passed()) / float64(c.Finished) * 100
+	}
+	fmt.Fprintf(w, "\n--- %s tcping statistics ---\n", target)
+	fmt.Fprintf(w, "%d probes sent, %d succeeded", c.Finished, c.Succeed)
+	if c.OpenFiltered > 0 {
+		fmt.Fprintf(w, ", %d open|filtered", c.OpenFiltered)
+	}
+	fmt.Fprintf(w, ", %.1f%% loss, time %s\n", loss, duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "errors: connect %d, timeout %d, other %d", c.ErrConnect, c.ErrTimeout, c.ErrOther)
+	if c.SYNDropped > 0 {
+		fmt.Fprintf(w, ", syn dropped %d", c.SYNDropped)
+	}
+	if c.ErrLocal > 0 {
+		fmt.Fprintf(w, ", local %d", c.ErrLocal)
+	}
+	fmt.Fprintln(w)
+	if latency.Count() > 0 {
+		fmt.Fprintf(w, "rtt min/avg/max/stddev = %.3f/%.3f/%.3f/%.3f ms\n",
+			ms(us(latency.Min())), ms(usf(latency.Mean())), ms(us(latency.Max())), ms(usf(latency.StdDev())))
+	}
+}
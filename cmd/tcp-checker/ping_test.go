@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPingSummaryAgreesWithExitCode(t *testing.T) {
+	for _, c := range []struct {
+		counts Counts
+		line   string
+		code   int
+	}{
+		{Counts{Finished: 2, Succeed: 2}, "2 probes sent, 2 succeeded, 0.0% loss", ExitOK},
+		{Counts{Finished: 2, OpenFiltered: 2}, "2 probes sent, 0 succeeded, 2 open|filtered, 0.0% loss", ExitOK},
+		{Counts{Finished: 4, Succeed: 1, OpenFiltered: 1, ErrTimeout: 2}, "4 probes sent, 1 succeeded, 1 open|filtered, 50.0% loss", ExitSomeFailed},
+		{Counts{Finished: 2, ErrConnect: 2}, "2 probes sent, 0 succeeded, 100.0% loss", ExitAllFailed},
+	} {
+		var buf bytes.Buffer
+		writePingSummary(&buf, "127.0.0.1:80", c.counts, newHistogram(), time.Second)
+		if !strings.Contains(buf.String(), c.line) {
+			t.Errorf("%+v: expected %q in the summary, got %q", c.counts, c.line, buf.String())
+		}
+		if code := exitCode(c.counts); code != c.code {
+			t.Errorf("%+v: expected exit code %d, got %d", c.counts, c.code, code)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+	"github.com/tevino/tcp-shaker/scan"
+)
+
+// ScanConfig contains the options of the scan command.
+type ScanConfig struct {
+	Config
+	Prefixes []netip.Prefix
+	Ports    []uint16
+	Seed     int64
+	OpenOnly bool
+}
+
+var scanOutputFormats = []string{OutputText, OutputNDJSON}
+
+// parseScanConfig parses the arguments of the scan command.
+func parseScanConfig(name string, args []string) (*ScanConfig, error) {
+	var conf ScanConfig
+	var common commonFlags
+	var limits limitFlags
+	var ports string
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [options] -p ports cidr...\n\n", name)
+		fmt.Fprintln(flags.Output(), "Sweep the ports of the addresses in the CIDR blocks in a random order,")
+		fmt.Fprintln(flags.Output(), "e.g. '-p 22,80,8000-8100 10.0.0.0/24'. Only scan networks you are authorized to audit.")
+		fmt.Fprintln(flags.Output(), "\nOptions:")
+		flags.PrintDefaults()
+	}
+	common.define(flags, &conf.Config)
+	flags.StringVar(&ports, "p", "", "Ports to scan, e.g. 22,80,8000-8100")
+	flags.IntVar(&conf.Concurrency, "c", 100, "Number of checks to perform simultaneously")
+	flags.Float64Var(&conf.Rate, "rate", 100, "Number of checks to start per second, 0 means as fast as -c allows")
+	flags.Int64Var(&conf.Seed, "seed", 0, "Seed of the random order of targets, 0 means a random one")
+	flags.BoolVar(&conf.OpenOnly, "open", false, "Only report open ports")
+	flags.StringVar(&conf.Output, "output", OutputText, "Format of the results written to stdout: text or ndjson")
+	limits.define(flags)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := common.apply(&conf.Config); err != nil {
+		return nil, err
+	}
+	limiter, err := limits.limiter()
+	if err != nil {
+		return nil, err
+	}
+	conf.Limiter = limiter
+	if conf.Probe != nil || conf.Proxy != nil || conf.ProxyHeader != nil {
+		return nil, errors.New("-probe, -proxy and -proxy-protocol are not supported by scan")
+	}
+	if conf.Output != OutputText && conf.Output != OutputNDJSON {
+		return nil, fmt.Errorf("invalid output format '%s'", conf.Output)
+	}
+	if conf.Concurrency < 1 || conf.Rate < 0 {
+		return nil, errors.New("-c must be positive and -rate must not be negative")
+	}
+	if flags.NArg() == 0 {
+		return nil, errors.New("at least one CIDR block is required")
+	}
+	if conf.Ports, err = scan.ParsePorts(ports); err != nil {
+		return nil, err
+	}
+	if conf.Prefixes, err = scan.ParsePrefixes(flags.Args()); err != nil {
+		return nil, err
+	}
+	if conf.Seed == 0 {
+		conf.Seed = time.Now().UnixNano()
+	}
+	return &conf, nil
+}
+
+// portStats counts the states of a port.
+type portStats struct {
+	Port     uint16 `json:"port"`
+	Open     uint64 `json:"open"`
+	Closed   uint64 `json:"closed"`
+	Filtered uint64 `json:"filtered"`
+	Error    uint64 `json:"error"`
+	// OpenFiltered counts the UDP ports which sent nothing back.
+	OpenFiltered uint64 `json:"open_filtered,omitempty"`
+}
+
+func (p *portStats) add(state scan.State) {
+	switch state {
+	case scan.StateOpen:
+		p.Open++
+	case scan.StateClosed:
+		p.Closed++
+	case scan.StateFiltered:
+		p.Filtered++
+	case scan.StateOpenFiltered:
+		p.OpenFiltered++
+	default:
+		p.Error++
+	}
+}
+
+// scanRecord is a result written in ndjson format.
+type scanRecord struct {
+	Type      string  `json:"type"`
+	Target    string  `json:"target"`
+	IP        string  `json:"ip"`
+	Port      uint16  `json:"port"`
+	State     string  `json:"state"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// scanSummary is the summary written in ndjson format.
+type scanSummary struct {
+	Type       string           `json:"type"`
+	Targets    uint64           `json:"targets"`
+	Scanned    uint64           `json:"scanned"`
+	DurationMS float64          `json:"duration_ms"`
+	Ports      []*portStats     `json:"ports"`
+	Throttle   *ThrottleSummary `json:"throttle,omitempty"`
+}
+
+// runScan sweeps the targets and returns the exit code.
+func runScan(name string, args []string) int {
+	conf, err := parseScanConfig(name, args)
+	if err != nil {
+		return usageExitCode(err)
+	}
+	logger := newLogger(conf.LogFormat, conf.LogLevel)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	checker := tcpshaker.NewChecker(tcpshaker.WithLogger(logger), tcpshaker.WithRawSYN(conf.RawSYN), tcpshaker.WithMode(conf.Mode), tcpshaker.WithUDPPayload(conf.UDPPayload), tcpshaker.WithSYNRetries(conf.SYNRetries), tcpshaker.WithSourcePool(conf.SourcePool))
+	loopCtx, stopLoop := context.WithCancel(context.Background())
+	defer stopLoop()
+	go func() {
+		if err := checker.CheckingLoop(loopCtx); err != nil {
+			logger.Error("Error during checking loop", "error", err)
+			os.Exit(ExitInternal)
+		}
+	}()
+	<-checker.WaitReady()
+
+	targets := scan.NewTargets(conf.Prefixes, conf.Ports)
+	logger.Debug("Scanning with the following configurations",
+		"prefixes", conf.Prefixes,
+		"ports", len(conf.Ports),
+		"targets", targets.Len(),
+		"rate", conf.Rate,
+		"concurrency", conf.Concurrency,
+		"seed", conf.Seed,
+	)
+	opts := []scan.Option{
+		scan.WithRate(conf.Rate),
+		scan.WithConcurrency(conf.Concurrency),
+		scan.WithTimeout(conf.Timeout),
+		scan.WithSeed(conf.Seed),
+	}
+	if conf.Limiter != nil {
+		opts = append(opts, scan.WithLimiter(conf.Limiter))
+	}
+	scanner := scan.NewScanner(checker, opts...)
+
+	w := os.Stdout
+	enc := json.NewEncoder(w)
+	stats := make(map[uint16]*portStats, len(conf.Ports))
+	for _, port := range conf.Ports {
+		stats[port] = &portStats{Port: port}
+	}
+	var scanned uint64
+	startedAt := time.Now()
+	err = scanner.Scan(ctx, targets, func(r scan.Result) {
+		scanned++
+		stats[r.Target.Port()].add(r.State)
+		if conf.OpenOnly && r.State != scan.StateOpen {
+			return
+		}
+		if conf.Output == OutputNDJSON {
+			rec := scanRecord{
+				Type:      "result",
+				Target:    r.Target.String(),
+				IP:        r.Target.Addr().String(),
+				Port:      r.Target.Port(),
+				State:     r.State.String(),
+				LatencyMS: ms(r.Latency),
+			}
+			if r.Err != nil {
+				rec.Error = r.Err.Error()
+			}
+			_ = enc.Encode(&rec)
+			return
+		}
+		if r.State == scan.StateOpen || r.State == scan.StateError {
+			writeScanResult(w, r)
+		}
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Error("Error during scanning", "error", err)
+		return ExitInternal
+	}
+
+	summary := scanSummary{
+		Type:       "summary",
+		Targets:    targets.Len(),
+		Scanned:    scanned,
+		DurationMS: ms(time.Since(startedAt)),
+		Throttle:   newThrottleSummary(conf.Limiter),
+	}
+	if summary.Throttle != nil {
+		logger.Info(fmt.Sprintf("Throttled %d/%d checks", summary.Throttle.Throttled, summary.Throttle.Acquired), throttleAttrs(summary.Throttle)...)
+	}
+	for _, port := range conf.Ports {
+		summary.Ports = append(summary.Ports, stats[port])
+	}
+	if conf.Output == OutputNDJSON {
+		_ = enc.Encode(&summary)
+	} else {
+		writeScanSummary(w, &summary)
+	}
+	return ExitOK
+}
+
+func writeScanResult(w io.Writer, r scan.Result) {
+	if r.Err != nil && r.State == scan.StateError {
+		fmt.Fprintf(w, "%-24s %-8s %s\n", r.Target, r.State, r.Err)
+		return
+	}
+	fmt.Fprintf(w, "%-24s %-8s %.3f ms\n", r.Target, r.State, ms(r.Latency))
+}
+
+// writeScanSummary writes the states of the ports which are not all filtered or closed.
+func writeScanSummary(w io.Writer, s *scanSummary) {
+	fmt.Fprintf(w, "\nScanned %d/%d targets in %s\n", s.Scanned, s.Targets, time.Duration(s.DurationMS*float64(time.Millisecond)).Round(time.Millisecond))
+	ports := make([]*portStats, 0, len(s.Ports))
+	var udp bool
+	for _, p := range s.Ports {
+		if p.Open > 0 || p.Error > 0 || p.OpenFiltered > 0 {
+			ports = append(ports, p)
+		}
+		udp = udp || p.OpenFiltered > 0
+	}
+	if len(ports) == 0 {
+		fmt.Fprintln(w, "No open port found")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if udp {
+		fmt.Fprintln(tw, "PORT\tOPEN\tOPEN|FILTERED\tCLOSED\tFILTERED\tERROR")
+	} else {
+		fmt.Fprintln(tw, "PORT\tOPEN\tCLOSED\tFILTERED\tERROR")
+	}
+	for _, p := range ports {
+		if udp {
+			fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%d\n", p.Port, p.Open, p.OpenFiltered, p.Closed, p.Filtered, p.Error)
+		} else {
+			fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\n", p.Port, p.Open, p.Closed, p.Filtered, p.Error)
+		}
+	}
+	_ = tw.Flush()
+}
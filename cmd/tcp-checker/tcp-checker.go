@@ -2,219 +2,194 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
-	"log"
-	"net"
+	"fmt"
+	"log/slog"
+	"math"
 	"os"
 	"os/signal"
-	"sync"
-	"sync/atomic"
 	"syscall"
 	"time"
-
-	tcpshaker "github.com/tevino/tcp-shaker"
 )
 
-// Counter is an atomic counter for multiple metrics.
-type Counter struct {
-	counters map[int]*uint64
-}
-
-// NewCounter creates Counter with given IDs.
-func NewCounter(ids ...int) *Counter {
-	counter := &Counter{}
-	counter.Declare(ids...)
-	return counter
-}
-
-// Declare declares the ID of counters.
-// NOTE: This must be called before counting, and should only be called once.
-func (c *Counter) Declare(ids ...int) {
-	c.counters = make(map[int]*uint64, len(ids))
-	for _, id := range ids {
-		var i uint64
-		c.counters[id] = &i
+func main() {
+	os.Exit(run())
+}
+
+// run runs the command given by os.Args and returns the exit code,
+// the deferred calls are run before exiting.
+func run() (code int) {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "ping":
+			return runPing(os.Args[0]+" ping", os.Args[2:])
+		case "scan":
+			return runScan(os.Args[0]+" scan", os.Args[2:])
+		}
 	}
-}
-
-// Inc increases the counter of given ID by one and returns the new value.
-func (c *Counter) Inc(i int) uint64 {
-	return atomic.AddUint64(c.counters[i], 1)
-}
 
-// Count returns the value of counter with given ID.
-func (c *Counter) Count(i int) uint64 {
-	return atomic.LoadUint64(c.counters[i])
-}
-
-// Available counter names.
-const (
-	CRequest int = iota
-	CSucceed
-	CErrConnect
-	CErrTimeout
-	CErrOther
-)
-
-// Config contains all available options.
-type Config struct {
-	Addr        string
-	Timeout     time.Duration
-	Requests    int
-	Concurrency int
-	Verbose     bool
-}
+	conf, err := parseConfig(os.Args[0], os.Args[1:], os.Stdin)
+	if err != nil {
+		return usageExitCode(err)
+	}
+	logger := newLogger(conf.LogFormat, conf.LogLevel)
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error("Unexpected panic", "error", err)
+			code = ExitInternal
+		}
+	}()
 
-func parseConfig() *Config {
-	var conf Config
-	var timeoutMS int
-	// Flag definition
-	flag.IntVar(&timeoutMS, "t", 1000, "Timeout in millisecond for the whole checking process(domain resolving is included)")
-	flag.StringVar(&conf.Addr, "a", "example.com:80", "TCP address to test")
-	flag.IntVar(&conf.Requests, "n", 1, "Number of requests to perform")
-	flag.IntVar(&conf.Concurrency, "c", 1, "Number of checks to perform simultaneously")
-	flag.BoolVar(&conf.Verbose, "v", false, "Print more logs e.g. error detail")
-	// Parse flags
-	flag.Parse()
-	if _, err := net.ResolveTCPAddr("tcp", conf.Addr); err != nil {
-		log.Fatalf("Can not resolve '%s': %s", conf.Addr, err)
-	}
-	conf.Timeout = time.Duration(timeoutMS) * time.Millisecond
-	return &conf
-}
+	logger.Debug("Checking with the following configurations",
+		"targets", conf.Targets,
+		"timeout", conf.Timeout,
+		"requests", conf.Requests,
+		"concurrency", conf.Concurrency,
+		"rate", conf.Rate,
+		"duration", conf.Duration,
+	)
+
+	output := newOutput(conf.Output, os.Stdout)
+	checker := NewConcurrentChecker(conf, logger, output)
+	defer checker.Stop()
 
-// ConcurrentChecker is a wrapper of tcpshaker.Checker with concurrent checking capabilities.
-type ConcurrentChecker struct {
-	conf    *Config
-	counter *Counter
-	checker *tcpshaker.Checker
-	queue   chan bool
-	closed  chan bool
-	wg      sync.WaitGroup
-}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	startedAt := time.Now()
 
-// NewConcurrentChecker creates a checker.
-func NewConcurrentChecker(conf *Config) *ConcurrentChecker {
-	return &ConcurrentChecker{
-		conf:    conf,
-		counter: NewCounter(CRequest, CSucceed, CErrConnect, CErrTimeout, CErrOther),
-		checker: tcpshaker.NewChecker(),
-		queue:   make(chan bool),
-		closed:  make(chan bool),
+	checker.Launch(ctx)
+	if conf.Progress > 0 {
+		go reportProgress(ctx, logger, checker, conf.Progress)
 	}
-}
+	select {
+	case <-ctx.Done():
+	case <-checker.Wait():
+	}
+	duration := time.Since(startedAt)
+	stop()
 
-// Count returns the count of given ID.
-func (cc *ConcurrentChecker) Count(i int) uint64 {
-	return cc.counter.Count(i)
+	summary := newSummary(checker, startedAt, duration)
+	logSummary(logger, summary)
+	if err := output.WriteSummary(summary); err != nil {
+		logger.Error("Error writing output", "error", err)
+		return ExitInternal
+	}
+	if err := writeHistory(conf); err != nil {
+		logger.Error("Error writing history", "error", err)
+		return ExitInternal
+	}
+	return exitCode(summary.Counts)
 }
 
-// Launch initialize the checker.
-func (cc *ConcurrentChecker) Launch(ctx context.Context) {
-	go func() {
-		err := cc.checker.CheckingLoop(ctx)
-		if err != nil {
-			log.Fatal("Error during checking loop: ", err)
-		}
-	}()
-
-	for i := 0; i < cc.conf.Concurrency; i++ {
-		go cc.worker()
+// writeHistory writes the report of the result history to conf.HistoryFile if enabled.
+func writeHistory(conf *Config) error {
+	if conf.History == nil {
+		return nil
 	}
-	cc.wg.Add(cc.conf.Requests)
-
-	if cc.conf.Verbose {
-		log.Println("Waiting for checker to be ready")
+	f, err := os.Create(conf.HistoryFile)
+	if err != nil {
+		return err
 	}
-	<-cc.checker.WaitReady()
-
-	go func() {
-		for i := 0; i < cc.conf.Requests; i++ {
-			cc.queue <- true
-		}
-	}()
+	if err := conf.History.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// newSummary creates the Summary of the checks done by checker.
+func newSummary(checker *ConcurrentChecker, startedAt time.Time, duration time.Duration) *Summary {
+	conf := checker.conf
+	latency := checker.Latency()
+	summary := &Summary{
+		StartedAt:  startedAt,
+		DurationMS: ms(duration),
+		Counts:     newCounts(checker.Total(), checker.Count),
+		Latency:    newLatencySummary(latency),
+		Throttle:   newThrottleSummary(conf.Limiter),
+		Targets:    make([]TargetSummary, 0, len(conf.Targets)),
+		latency:    latency,
+	}
+	for _, target := range conf.Targets {
+		count := func(i int) uint64 { return checker.TargetCount(target, i) }
+		summary.Targets = append(summary.Targets, TargetSummary{
+			Target:  target,
+			Counts:  newCounts(checker.TargetTotal(target), count),
+			Latency: newLatencySummary(checker.TargetLatency(target)),
+		})
+	}
+	return summary
 }
 
-func (cc *ConcurrentChecker) doCheck() {
-	err := cc.checker.CheckAddr(cc.conf.Addr, cc.conf.Timeout)
-	cc.counter.Inc(CRequest)
-	switch err {
-	case tcpshaker.ErrTimeout:
-		cc.counter.Inc(CErrTimeout)
-	case nil:
-		cc.counter.Inc(CSucceed)
-	default:
-		if cc.conf.Verbose {
-			log.Println(err)
-		}
-		if _, ok := err.(*tcpshaker.ErrConnect); ok {
-			cc.counter.Inc(CErrConnect)
-		} else {
-			cc.counter.Inc(CErrOther)
+// logSummary logs the counters of every target followed by the total.
+func logSummary(logger *slog.Logger, summary *Summary) {
+	if len(summary.Targets) > 1 {
+		for _, target := range summary.Targets {
+			attrs := append([]any{"addr", target.Target}, countsAttrs(target.Counts)...)
+			attrs = append(attrs, latencyAttrs(target.Latency)...)
+			logger.Info(fmt.Sprintf("Finished %d/%d checks of %s", target.Finished, target.Requests, target.Target), attrs...)
 		}
 	}
-}
 
-// Wait returns a chan which is closed when all checks are done.
-func (cc *ConcurrentChecker) Wait() chan bool {
-	c := make(chan bool)
-	go func() {
-		cc.wg.Wait()
-		close(c)
-	}()
-	return c
+	var attrs []any
+	if len(summary.Targets) == 1 {
+		attrs = append(attrs, "addr", summary.Targets[0].Target)
+	}
+	duration := time.Duration(summary.DurationMS * float64(time.Millisecond))
+	attrs = append(attrs, countsAttrs(summary.Counts)...)
+	attrs = append(attrs, latencyAttrs(summary.Latency)...)
+	attrs = append(attrs, throttleAttrs(summary.Throttle)...)
+	attrs = append(attrs, "duration", duration)
+	logger.Info(fmt.Sprintf("Finished %d/%d checks in %s", summary.Finished, summary.Requests, duration), attrs...)
+}
+
+func countsAttrs(c Counts) []any {
+	attrs := []any{
+		"finished", c.Finished,
+		"requests", c.Requests,
+		"succeed", c.Succeed,
+		"err_connect", c.ErrConnect,
+		"err_timeout", c.ErrTimeout,
+		"err_other", c.ErrOther,
+	}
+	if c.SYNDropped > 0 {
+		attrs = append(attrs, "syn_dropped", c.SYNDropped)
+	}
+	if c.ErrLocal > 0 {
+		attrs = append(attrs, "err_local", c.ErrLocal)
+	}
+	if c.OpenFiltered > 0 {
+		attrs = append(attrs, "open_filtered", c.OpenFiltered)
+	}
+	return attrs
 }
 
-// Stop stops the workers.
-func (cc *ConcurrentChecker) Stop() {
-	close(cc.closed)
+// usageExitCode prints the error of parsing arguments and returns the exit code.
+func usageExitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	fmt.Fprintln(os.Stderr, err)
+	return ExitUsage
 }
 
-func (cc *ConcurrentChecker) worker() {
+// reportProgress logs the progress of checker at given interval until ctx is done.
+func reportProgress(ctx context.Context, logger *slog.Logger, checker *ConcurrentChecker, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	startedAt := time.Now()
+	var lastFinished uint64
 	for {
 		select {
-		case <-cc.queue:
-			cc.doCheck()
-			cc.wg.Done()
-		case <-cc.closed:
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
 		}
+		finished := checker.Count(CRequest)
+		rate := float64(finished-lastFinished) / interval.Seconds()
+		lastFinished = finished
+		attrs := append([]any{"elapsed", time.Since(startedAt).Round(time.Second), "rate", math.Round(rate*10) / 10},
+			countsAttrs(newCounts(checker.Total(), checker.Count))...)
+		logger.Info(fmt.Sprintf("Progress: %d checks finished", finished), attrs...)
 	}
 }
-
-func main() {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Fatal(err)
-		}
-	}()
-	log.SetFlags(0)
-	conf := parseConfig()
-
-	if conf.Verbose {
-		log.Printf(`Checking %s with the following configurations:
-    Timeout: %s
-   Requests: %d
-Concurrency: %d
-
-`, conf.Addr, conf.Timeout, conf.Requests, conf.Concurrency)
-	}
-
-	checker := NewConcurrentChecker(conf)
-	defer checker.Stop()
-
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	startedAt := time.Now()
-
-	checker.Launch(ctx)
-	select {
-	case <-ctx.Done():
-	case <-checker.Wait():
-	}
-	duration := time.Since(startedAt)
-	stop()
-
-	log.Printf("Finished %d/%d checks in %s\n", checker.Count(CRequest), conf.Requests, duration)
-	log.Printf("  Succeed: %d\n", checker.Count(CSucceed))
-	log.Printf("  Errors: connect %d, timeout %d, other %d\n", checker.Count(CErrConnect), checker.Count(CErrTimeout), checker.Count(CErrOther))
-}
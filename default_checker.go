@@ -2,42 +2,132 @@ package tcp
 
 import (
 	"context"
-	"log"
-	"os"
-	"os/signal"
 	"sync"
-	"syscall"
 )
 
 var (
+	defaultLock    sync.Mutex
 	defaultChecker *Checker
-	once           sync.Once
+	// defaultLoop is nil if defaultChecker is set by SetDefaultChecker.
+	defaultLoop *checkingLoop
 )
 
+// checkingLoop runs the CheckingLoop of a Checker in a goroutine.
+type checkingLoop struct {
+	checker *Checker
+	parent  context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	err     error
+}
+
+func startCheckingLoop(parent context.Context, checker *Checker) *checkingLoop {
+	ctx, cancel := context.WithCancel(parent)
+	l := &checkingLoop{
+		checker: checker,
+		parent:  parent,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	isReady := checker.WaitReady()
+	go func() {
+		defer close(l.done)
+		// If the checking loop stops with an error, we log it.
+		if err := checker.CheckingLoop(ctx); err != nil {
+			l.err = err
+			checker.log().Error("tcpshaker: TCP checking loop stopped with an error", "error", err)
+		}
+	}()
+
+	// Wait for the checker to be ready to ensure initialization is complete.
+	select {
+	case <-isReady:
+	case <-l.done:
+	}
+	return l
+}
+
+// failed returns true if the loop stopped with an error while its parent
+// context is still alive.
+func (l *checkingLoop) failed() bool {
+	select {
+	case <-l.done:
+		return l.err != nil && l.parent.Err() == nil
+	default:
+		return false
+	}
+}
+
+// stop stops the loop and waits for it to exit.
+func (l *checkingLoop) stop() {
+	l.cancel()
+	<-l.done
+}
+
 // DefaultChecker returns a shared singleton instance of the Checker.
 //
-// It starts the Checker's CheckingLoop in a goroutine with a context that
-// listens for system signals (SIGINT, SIGTERM) to stop gracefully. This function
-// blocks until the Checker is ready for use.
+// Unless InitDefault or SetDefaultChecker is called beforehand, a Checker is
+// created with default options and its CheckingLoop is started in a goroutine
+// which runs until the process exits. This function blocks until the Checker
+// is ready for use.
+//
+// If the CheckingLoop started by DefaultChecker or InitDefault stopped with an
+// error, it is restarted on the next call, the same instance is returned.
+// NOTE: The CheckingLoop is not restarted once the context given to
+// InitDefault is done, call InitDefault again to restart it.
 func DefaultChecker() *Checker {
-	once.Do(func() {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+
+	switch {
+	case defaultChecker == nil:
 		defaultChecker = NewChecker()
+		defaultLoop = startCheckingLoop(context.Background(), defaultChecker)
+	case defaultLoop != nil && defaultLoop.failed():
+		defaultLoop = startCheckingLoop(defaultLoop.parent, defaultChecker)
+	}
+	return defaultChecker
+}
 
-		go func() {
-			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-			defer stop()
+// InitDefault replaces the Checker returned by DefaultChecker with a new one
+// created with given options, its CheckingLoop runs until ctx is done.
+// The previous one is stopped if it was started by DefaultChecker or InitDefault.
+//
+// This function blocks until the Checker is ready for use, the error of
+// CheckingLoop is returned if it failed to start.
+func InitDefault(ctx context.Context, opts ...Option) (*Checker, error) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
 
-			// If the checking loop stops with an error, we log it.
-			// We use the standard log package to avoid external dependencies.
-			if err := defaultChecker.CheckingLoop(ctx); err != nil {
-				log.Printf("tcpshaker: TCP checking loop stopped with an error: %v", err)
-			}
-		}()
+	resetDefault()
 
-		// Wait for the checker to be ready to ensure initialization is complete
-		// before returning the instance.
-		<-defaultChecker.WaitReady()
-	})
+	checker := NewChecker(opts...)
+	loop := startCheckingLoop(ctx, checker)
+	if loop.failed() {
+		return nil, loop.err
+	}
+	defaultChecker, defaultLoop = checker, loop
+	return checker, nil
+}
 
-	return defaultChecker
+// SetDefaultChecker replaces the Checker returned by DefaultChecker with the
+// given one, whose CheckingLoop is managed by the caller.
+// The previous one is stopped if it was started by DefaultChecker or InitDefault.
+// Setting nil makes the next call of DefaultChecker create a new one.
+func SetDefaultChecker(checker *Checker) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+
+	resetDefault()
+	defaultChecker = checker
+}
+
+// resetDefault stops the CheckingLoop of the default Checker if it is managed
+// by this package and resets the default Checker.
+// NOTE: defaultLock must be held.
+func resetDefault() {
+	if defaultLoop != nil {
+		defaultLoop.stop()
+	}
+	defaultChecker, defaultLoop = nil, nil
 }
@@ -0,0 +1,49 @@
+package tcp
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestDefaultCheckerRecover(t *testing.T) {
+	t.Cleanup(func() { SetDefaultChecker(nil) })
+
+	checker := DefaultChecker()
+	defaultLock.Lock()
+	loop := defaultLoop
+	defaultLock.Unlock()
+
+	// Replace the poller with a non-epoll fd to break the checking loop.
+	fd, err := unix.Socket(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fd)
+	if err := unix.Dup2(fd, checker.PollerFd()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-loop.done:
+	case <-time.After(pollerTimeout * 3):
+		t.Fatalf("Checking loop did not stop")
+	}
+	if loop.err == nil {
+		t.Fatalf("Checking loop should have stopped with an error")
+	}
+
+	if DefaultChecker() != checker {
+		t.Fatalf("DefaultChecker() did not return the same instance after recovering")
+	}
+	if !checker.IsReady() {
+		t.Fatalf("DefaultChecker() did not restart the checking loop")
+	}
+
+	testAddr, stopServer := StartTestServer()
+	defer stopServer()
+	if err := checker.CheckAddr(testAddr, time.Second); err != nil {
+		t.Fatalf("Check against test server failed with error: %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package tcp
 
 import (
+	"context"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
@@ -124,3 +126,62 @@ func TestDefaultCheckerOnceBehavior(t *testing.T) {
 		}
 	}
 }
+
+func TestInitDefault(t *testing.T) {
+	t.Cleanup(func() { SetDefaultChecker(nil) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	checker, err := InitDefault(ctx, WithZeroLinger(false))
+	if err != nil {
+		t.Fatalf("InitDefault() failed with error: %v", err)
+	}
+	if DefaultChecker() != checker {
+		t.Fatalf("DefaultChecker() did not return the instance created by InitDefault()")
+	}
+	if checker.zeroLinger {
+		t.Fatalf("Options given to InitDefault() are not applied")
+	}
+
+	testAddr, stopServer := StartTestServer()
+	defer stopServer()
+	if err := checker.CheckAddr(testAddr, time.Second); err != nil {
+		t.Fatalf("Check against test server failed with error: %v", err)
+	}
+
+	// Stop the checker
+	cancel()
+	defaultLock.Lock()
+	<-defaultLoop.done
+	defaultLock.Unlock()
+	if checker.IsReady() {
+		t.Fatalf("Checker should be stopped after the context is canceled")
+	}
+
+	// Restart with a new one
+	restarted, err := InitDefault(context.Background())
+	if err != nil {
+		t.Fatalf("InitDefault() failed with error: %v", err)
+	}
+	if restarted == checker || !restarted.IsReady() {
+		t.Fatalf("InitDefault() did not restart the default checker")
+	}
+}
+
+func TestSetDefaultChecker(t *testing.T) {
+	t.Cleanup(func() { SetDefaultChecker(nil) })
+
+	previous := DefaultChecker()
+	checker := NewChecker()
+	SetDefaultChecker(checker)
+	if DefaultChecker() != checker {
+		t.Fatalf("DefaultChecker() did not return the instance set by SetDefaultChecker()")
+	}
+	if runtime.GOOS == "linux" && previous.IsReady() {
+		t.Fatalf("The previous default checker should be stopped")
+	}
+
+	SetDefaultChecker(nil)
+	if DefaultChecker() == checker {
+		t.Fatalf("DefaultChecker() should create a new instance after SetDefaultChecker(nil)")
+	}
+}
@@ -30,5 +30,16 @@ By avoiding the last ACK
 The second one is essential because it bothers the server less.
 
 This means the application level server will not notice the health checking traffic at all, thus the act of health checking will not be considered as some misbehavior of client.
+
+# Verification
+
+With zero linger, the default, the sequence is SYN, SYN-ACK, RST: the RST is
+sent by closing the socket before the delayed ACK is sent. With
+WithZeroLinger(false) the sequence is SYN, SYN-ACK, FIN-ACK instead, the FIN
+acknowledges the SYN-ACK, i.e. the handshake is completed from the view of the
+server. WithRawSYN crafts the packets itself and never completes the handshake.
+
+The capture package records the packets of checks to verify the sequence,
+see also the -capture option of "tcp-checker ping".
 */
 package tcp
@@ -1,7 +1,10 @@
 package tcp
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"syscall"
 )
 
 // ErrTimeout indicates I/O timeout
@@ -13,11 +16,85 @@ func (e *timeoutError) Error() string   { return "I/O timeout" }
 func (e *timeoutError) Timeout() bool   { return true }
 func (e *timeoutError) Temporary() bool { return true }
 
+// ErrOpenFiltered indicates neither a response nor an ICMP error was received
+// from a UDP port before the deadline, i.e. the port is open or filtered.
+// It matches ErrTimeout with errors.Is.
+var ErrOpenFiltered = &openFilteredError{}
+
+type openFilteredError struct{ timeoutError }
+
+func (e *openFilteredError) Error() string { return "no response: open or filtered" }
+
+// Is makes errors.Is(ErrOpenFiltered, ErrTimeout) true.
+func (e *openFilteredError) Is(target error) bool { return target == ErrTimeout }
+
+// ErrSYNDropped indicates the SYNs were dropped in ModeBacklog, i.e. the target
+// is alive but dropped the SYNs, which Linux does silently while the accept
+// queue of the listener is full. Either the SYN-ACK was received only after
+// the SYN was retransmitted, or no SYN was answered while the host answered a
+// SYN to port 1, which is Unanswered.
+// NOTE: a port filtered by a firewall of a host answering port 1 is
+// indistinguishable from a full listener, a host answering nothing is
+// reported as ErrTimeout.
+type ErrSYNDropped struct {
+	// Retransmits is the number of SYNs retransmitted, before the SYN-ACK if any.
+	Retransmits int
+	// Unanswered is true if none of the SYNs was answered.
+	Unanswered bool
+}
+
+func (e *ErrSYNDropped) Error() string {
+	if e.Unanswered {
+		return fmt.Sprintf("SYN dropped %d time(s) without a SYN-ACK while the host is up: listener overloaded", e.Retransmits+1)
+	}
+	return fmt.Sprintf("SYN dropped %d time(s) before the SYN-ACK: listener overloaded", e.Retransmits)
+}
+
 // ErrConnect is an error occurs while connecting to the host
 // To get the detail of underlying error, lookup ErrorCode() in 'man 2 connect'
 type ErrConnect struct {
 	error
 }
 
+// Unwrap returns the underlying error, e.g. syscall.ECONNREFUSED.
+func (e *ErrConnect) Unwrap() error { return e.error }
+
+// ErrSocketNotFound indicates the Unix domain socket does not exist(ENOENT).
+// A socket which exists without a listener is reported as ErrConnect wrapping ECONNREFUSED.
+var ErrSocketNotFound = &ErrConnect{syscall.ENOENT}
+
+// ErrBacklogFull indicates the listen backlog of the Unix domain socket is full(EAGAIN),
+// i.e. the listener is alive but does not accept fast enough.
+var ErrBacklogFull = &ErrConnect{syscall.EAGAIN}
+
+// unixConnectError converts the error of connecting to a Unix domain socket.
+func unixConnectError(err error) error {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return err
+	}
+	switch errno {
+	case syscall.ENOENT:
+		return ErrSocketNotFound
+	case syscall.EAGAIN:
+		return ErrBacklogFull
+	}
+	return &ErrConnect{errno}
+}
+
+// WrapConnectError returns an ErrConnect with given underlying error, which
+// is useful for checks implemented outside of this package, e.g. probes.
+func WrapConnectError(err error) *ErrConnect {
+	return &ErrConnect{err}
+}
+
 // ErrCheckerAlreadyStarted indicates there is another instance of CheckingLoop running.
 var ErrCheckerAlreadyStarted = errors.New("Checker was already started")
+
+// ctxErr converts the error of a done ctx to ErrTimeout if its deadline exceeded.
+func ctxErr(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,105 @@
+package tcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/tevino/tcp-shaker/capture"
+)
+
+// TestHandshakeNotCompleted verifies the packet sequence documented in doc.go:
+// SYN, SYN-ACK, RST with zero linger, the final ACK is never sent.
+// Without zero linger the socket is closed with a FIN acknowledging the SYN-ACK.
+func TestHandshakeNotCompleted(t *testing.T) {
+	for _, zeroLinger := range []bool{true, false} {
+		t.Run(fmt.Sprintf("zeroLinger=%v", zeroLinger), func(t *testing.T) {
+			c := NewChecker(WithZeroLinger(zeroLinger))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() { _ = c.CheckingLoop(ctx) }()
+			<-c.WaitReady()
+
+			packets := captureHandshake(t, c)
+			kinds := capture.Kinds(packets)
+			if zeroLinger && (!capture.Aborted(packets) || capture.Completed(packets) || len(kinds) != 3) {
+				t.Fatalf("expected SYN, SYN-ACK, RST, got %v", packets)
+			}
+			if !zeroLinger && (!capture.Completed(packets) || kinds[2] != "FIN-ACK") {
+				t.Fatalf("expected SYN, SYN-ACK, FIN-ACK, got %v", packets)
+			}
+		})
+	}
+}
+
+func TestHandshakeNotCompletedRawSYN(t *testing.T) {
+	// The kernel sends an RST as well, since the SYN-ACK is unexpected to it.
+	if packets := captureHandshake(t, startSYNChecker(t)); !capture.Aborted(packets) {
+		t.Fatalf("expected SYN, SYN-ACK, RST, got %v", packets)
+	}
+}
+
+func TestHandshakeModes(t *testing.T) {
+	c := NewChecker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.CheckingLoop(ctx) }()
+	<-c.WaitReady()
+
+	for mode, closedBy := range map[Mode]string{ModeConnect: "FIN-ACK", ModeConnectReset: "RST-ACK"} {
+		t.Run(mode.String(), func(t *testing.T) {
+			packets := captureHandshake(t, withMode{c, mode})
+			kinds := capture.Kinds(packets)
+			if !capture.Completed(packets) || len(kinds) < 4 || kinds[2] != "ACK" || kinds[3] != closedBy {
+				t.Fatalf("expected SYN, SYN-ACK, ACK, %s, got %v", closedBy, packets)
+			}
+		})
+	}
+}
+
+// checker performs checks in the tests of handshakes.
+type checker interface {
+	CheckAddr(addr string, timeout time.Duration) error
+}
+
+// withMode performs checks in a specific mode.
+type withMode struct {
+	*Checker
+	mode Mode
+}
+
+func (c withMode) CheckAddr(addr string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.CheckAddrMode(ctx, addr, c.mode)
+}
+
+// captureHandshake captures the packets of a check to a loopback listener.
+func captureHandshake(t *testing.T, c checker) []capture.Packet {
+	t.Helper()
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	port := netip.MustParseAddrPort(l.Addr().String()).Port()
+
+	capt, err := capture.Start(port)
+	if err != nil {
+		t.Skipf("packet capturing is not available: %v", err)
+	}
+	err = c.CheckAddr(l.Addr().String(), time.Second)
+	// Wait for the RST, or the delayed ACK if it is not suppressed.
+	time.Sleep(100 * time.Millisecond)
+	packets := capture.Handshake(capt.Stop(), port)
+	if err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	if kinds := capture.Kinds(packets); len(kinds) < 2 || kinds[0] != "SYN" || kinds[1] != "SYN-ACK" {
+		t.Fatalf("expected SYN, SYN-ACK, got %v", packets)
+	}
+	return packets
+}
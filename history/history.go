@@ -0,0 +1,333 @@
+// Package history keeps a bounded history of recent check results per target
+// and computes rolling availability, latency and flap statistics over time
+// windows, e.g. for SLO reports without a separate database.
+//
+// Results are recorded either by registering the Observer of a History with
+// tcpshaker.WithObserver, or explicitly with History.Record, e.g. for the
+// results of probe.Checker.
+package history
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+)
+
+// Outcome is the classified result of a check.
+type Outcome string
+
+// Outcomes of a check.
+const (
+	OutcomeOK           Outcome = "ok"
+	OutcomeConnectError Outcome = "connect_error"
+	OutcomeTimeout      Outcome = "timeout"
+	OutcomeSYNDropped   Outcome = "syn_dropped"
+	OutcomeLocalError   Outcome = "local_error"
+	// OutcomeOpenFiltered is a UDP port without response, which is open or
+	// filtered, see tcpshaker.ErrOpenFiltered. It counts as available.
+	OutcomeOpenFiltered Outcome = "open_filtered"
+	OutcomeError        Outcome = "error"
+)
+
+// available returns whether the outcome counts as available.
+func (o Outcome) available() bool {
+	return o == OutcomeOK || o == OutcomeOpenFiltered
+}
+
+// OutcomeOf classifies the error returned by a check, e.g. by probe.Checker
+// or a tcpshaker.Checker.
+func OutcomeOf(err error) Outcome {
+	var (
+		localErr   *tcpshaker.ErrLocalResource
+		synDropped *tcpshaker.ErrSYNDropped
+		connectErr *tcpshaker.ErrConnect
+	)
+	switch {
+	case err == nil:
+		return OutcomeOK
+	case errors.As(err, &localErr):
+		return OutcomeLocalError
+	case errors.Is(err, tcpshaker.ErrOpenFiltered):
+		// It matches ErrTimeout too.
+		return OutcomeOpenFiltered
+	case errors.Is(err, tcpshaker.ErrTimeout):
+		return OutcomeTimeout
+	case errors.As(err, &synDropped):
+		return OutcomeSYNDropped
+	case errors.As(err, &connectErr):
+		return OutcomeConnectError
+	}
+	return OutcomeError
+}
+
+// Result is the result of a single check.
+type Result struct {
+	Time    time.Time
+	Outcome Outcome
+	// Latency is the duration of the check.
+	Latency time.Duration
+	// Errno is the errno wrapped by the error of the check if any.
+	Errno syscall.Errno
+}
+
+// NewResult creates the Result of a check started at start with given error.
+func NewResult(start time.Time, err error, latency time.Duration) Result {
+	r := Result{Time: start, Outcome: OutcomeOf(err), Latency: latency}
+	errors.As(err, &r.Errno)
+	return r
+}
+
+// DefaultCapacity is the default number of results kept per target,
+// an hour of results at an interval of a second.
+const DefaultCapacity = 3600
+
+// DefaultWindows are the default windows of Summary and Report.
+var DefaultWindows = []time.Duration{time.Minute, 5 * time.Minute, time.Hour}
+
+// Option configures a History.
+type Option func(*History)
+
+// WithCapacity sets the number of results kept per target, the oldest result
+// is dropped once it is exceeded. Statistics of a window longer than the
+// kept results cover are marked as Truncated.
+func WithCapacity(n int) Option {
+	return func(h *History) {
+		h.capacity = n
+	}
+}
+
+// WithWindows sets the windows of Summary and Report.
+func WithWindows(windows ...time.Duration) Option {
+	return func(h *History) {
+		h.windows = windows
+	}
+}
+
+// History keeps the recent results of every target in a ring buffer.
+// It is safe for concurrent use.
+type History struct {
+	capacity int
+	windows  []time.Duration
+	// now returns the current time, replaced in tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	targets map[string]*ring
+}
+
+// New creates a History.
+func New(opts ...Option) *History {
+	h := &History{
+		capacity: DefaultCapacity,
+		windows:  DefaultWindows,
+		now:      time.Now,
+		targets:  make(map[string]*ring),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.capacity < 1 {
+		h.capacity = 1
+	}
+	return h
+}
+
+// Record records the result of a check of target.
+func (h *History) Record(target string, r Result) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rg, ok := h.targets[target]
+	if !ok {
+		rg = &ring{results: make([]Result, 0, min(h.capacity, 64)), capacity: h.capacity}
+		h.targets[target] = rg
+	}
+	rg.push(r)
+}
+
+// Targets returns the recorded targets in lexical order.
+func (h *History) Targets() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	targets := make([]string, 0, len(h.targets))
+	for target := range h.targets {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// Results returns the kept results of target, the oldest first.
+func (h *History) Results(target string) []Result {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if rg, ok := h.targets[target]; ok {
+		return rg.slice()
+	}
+	return nil
+}
+
+// Stats returns the statistics of the results of target within the window
+// ending now, ok is false if target is never recorded.
+func (h *History) Stats(target string, window time.Duration) (s Stats, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rg, ok := h.targets[target]
+	if !ok {
+		return Stats{}, false
+	}
+	return rg.stats(h.now(), window), true
+}
+
+// Summary returns the statistics of target within every window of the History.
+func (h *History) Summary(target string) []Stats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rg, ok := h.targets[target]
+	if !ok {
+		return nil
+	}
+	now := h.now()
+	stats := make([]Stats, 0, len(h.windows))
+	for _, window := range h.windows {
+		stats = append(stats, rg.stats(now, window))
+	}
+	return stats
+}
+
+// Reset forgets the results of all targets.
+func (h *History) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clear(h.targets)
+}
+
+// ring is a ring buffer of the results of a target.
+type ring struct {
+	results  []Result
+	capacity int
+	// start is the index of the oldest result once the ring is full.
+	start int
+	// dropped is whether any result is dropped.
+	dropped bool
+}
+
+func (rg *ring) push(r Result) {
+	if len(rg.results) < rg.capacity {
+		rg.results = append(rg.results, r)
+		return
+	}
+	rg.results[rg.start] = r
+	rg.start = (rg.start + 1) % rg.capacity
+	rg.dropped = true
+}
+
+// slice returns a copy of the results, the oldest first.
+func (rg *ring) slice() []Result {
+	results := make([]Result, 0, len(rg.results))
+	results = append(results, rg.results[rg.start:]...)
+	return append(results, rg.results[:rg.start]...)
+}
+
+// stats computes the statistics of the results in (now-window, now].
+func (rg *ring) stats(now time.Time, window time.Duration) Stats {
+	since := now.Add(-window)
+	s := Stats{Window: window, Outcomes: make(map[Outcome]int)}
+	var (
+		latencies []time.Duration
+		last      Outcome
+		oldest    time.Time
+	)
+	for _, r := range rg.slice() {
+		if oldest.IsZero() {
+			oldest = r.Time
+		}
+		if !r.Time.After(since) || r.Time.After(now) {
+			continue
+		}
+		if s.First.IsZero() {
+			s.First = r.Time
+		}
+		s.Last = r.Time
+		s.Results++
+		s.Outcomes[r.Outcome]++
+		switch r.Outcome {
+		case OutcomeLocalError:
+			// Not the fault of the target.
+			s.Excluded++
+			continue
+		case OutcomeOK:
+			s.Available++
+			latencies = append(latencies, r.Latency)
+		case OutcomeOpenFiltered:
+			// The latency is the deadline rather than of a response.
+			s.Available++
+		default:
+			s.Unavailable++
+		}
+		if last != "" && last.available() != r.Outcome.available() {
+			s.Flaps++
+		}
+		last = r.Outcome
+	}
+	s.Truncated = rg.dropped && oldest.After(since)
+	if checked := s.Available + s.Unavailable; checked > 0 {
+		s.Availability = float64(s.Available) / float64(checked)
+	}
+	if len(latencies) > 0 {
+		var sum time.Duration
+		for _, l := range latencies {
+			sum += l
+		}
+		s.MeanLatency = sum / time.Duration(len(latencies))
+		slices.Sort(latencies)
+		s.P50 = percentile(latencies, 50)
+		s.P90 = percentile(latencies, 90)
+		s.P99 = percentile(latencies, 99)
+	}
+	return s
+}
+
+// percentile returns the p-th percentile of sorted by the nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// Observer returns a tcpshaker.Observer recording the result of every check
+// of a Checker, register it with tcpshaker.WithObserver.
+func (h *History) Observer() tcpshaker.Observer {
+	return &observer{history: h}
+}
+
+// observer records the results of a Checker into a History.
+type observer struct {
+	tcpshaker.NopObserver
+	history *History
+}
+
+// checkKey is the context key of the check being observed.
+type checkKey struct{}
+
+// check is the state of a check being observed.
+type check struct {
+	addr  string
+	start time.Time
+}
+
+func (o *observer) OnCheckStart(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, checkKey{}, &check{addr: addr, start: o.history.now()})
+}
+
+func (o *observer) OnCheckDone(ctx context.Context, err error, elapsed time.Duration) {
+	c, ok := ctx.Value(checkKey{}).(*check)
+	if !ok {
+		return
+	}
+	o.history.Record(c.addr, NewResult(c.start, err, elapsed))
+}
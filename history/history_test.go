@@ -0,0 +1,177 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+)
+
+// epoch is the current time of the histories of tests.
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestHistory creates a History whose current time is epoch.
+func newTestHistory(opts ...Option) *History {
+	h := New(opts...)
+	h.now = func() time.Time { return epoch }
+	return h
+}
+
+// ago returns the time d before epoch.
+func ago(d time.Duration) time.Time {
+	return epoch.Add(-d)
+}
+
+func TestOutcomeOf(t *testing.T) {
+	// ErrConnect is covered by TestObserver.
+	for err, expected := range map[error]Outcome{
+		nil:                                      OutcomeOK,
+		tcpshaker.ErrTimeout:                     OutcomeTimeout,
+		tcpshaker.ErrOpenFiltered:                OutcomeOpenFiltered,
+		&tcpshaker.ErrSYNDropped{Retransmits: 1}: OutcomeSYNDropped,
+		errors.New("unexpected"):                 OutcomeError,
+	} {
+		if outcome := OutcomeOf(err); outcome != expected {
+			t.Errorf("OutcomeOf(%v) = %s, expected %s", err, outcome, expected)
+		}
+	}
+	r := NewResult(epoch, fmt.Errorf("probe: %w", syscall.ECONNRESET), time.Millisecond)
+	if r.Errno != syscall.ECONNRESET || r.Outcome != OutcomeError || r.Latency != time.Millisecond {
+		t.Fatalf("unexpected result %+v", r)
+	}
+}
+
+func TestRing(t *testing.T) {
+	h := newTestHistory(WithCapacity(3))
+	for i := 0; i < 5; i++ {
+		h.Record("a", Result{Time: ago(time.Duration(5-i) * time.Second), Outcome: OutcomeOK, Latency: time.Duration(i)})
+	}
+	results := h.Results("a")
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	for i, r := range results {
+		if r.Latency != time.Duration(i+2) {
+			t.Fatalf("expected the latest results oldest first, got %+v", results)
+		}
+	}
+	if s, _ := h.Stats("a", time.Minute); !s.Truncated || s.Results != 3 {
+		t.Fatalf("expected truncated stats of 3 results, got %+v", s)
+	}
+	if s, _ := h.Stats("a", 2500*time.Millisecond); s.Truncated || s.Results != 2 {
+		t.Fatalf("expected complete stats of 2 results, got %+v", s)
+	}
+	if _, ok := h.Stats("b", time.Minute); ok || h.Results("b") != nil {
+		t.Fatal("expected no results of an unknown target")
+	}
+	h.Reset()
+	if len(h.Targets()) != 0 {
+		t.Fatal("expected no targets after Reset")
+	}
+}
+
+func TestStats(t *testing.T) {
+	h := newTestHistory()
+	// Older than 5m, only in the 1h window.
+	h.Record("a", Result{Time: ago(30 * time.Minute), Outcome: OutcomeTimeout})
+	h.Record("a", Result{Time: ago(10 * time.Minute), Outcome: OutcomeTimeout})
+	for i := 1; i <= 100; i++ {
+		h.Record("a", Result{Time: ago(4 * time.Minute).Add(time.Duration(i) * time.Second), Outcome: OutcomeOK, Latency: time.Duration(i) * time.Millisecond})
+	}
+	h.Record("a", Result{Time: ago(30 * time.Second), Outcome: OutcomeConnectError, Errno: syscall.ECONNREFUSED})
+	h.Record("a", Result{Time: ago(20 * time.Second), Outcome: OutcomeLocalError, Errno: syscall.EADDRNOTAVAIL})
+	h.Record("a", Result{Time: ago(10 * time.Second), Outcome: OutcomeOK, Latency: 200 * time.Millisecond})
+
+	summary := h.Summary("a")
+	if len(summary) != len(DefaultWindows) {
+		t.Fatalf("expected stats of %d windows, got %d", len(DefaultWindows), len(summary))
+	}
+	minute, fiveMinutes, hour := summary[0], summary[1], summary[2]
+
+	if minute.Results != 3 || minute.Available != 1 || minute.Unavailable != 1 || minute.Excluded != 1 {
+		t.Fatalf("unexpected 1m stats %+v", minute)
+	}
+	if minute.Availability != 0.5 || minute.Flaps != 1 || minute.MeanLatency != 200*time.Millisecond {
+		t.Fatalf("unexpected 1m stats %+v", minute)
+	}
+	if minute.First != ago(30*time.Second) || minute.Last != ago(10*time.Second) {
+		t.Fatalf("unexpected 1m range %s - %s", minute.First, minute.Last)
+	}
+
+	if fiveMinutes.Available != 101 || fiveMinutes.Unavailable != 1 || fiveMinutes.Flaps != 2 {
+		t.Fatalf("unexpected 5m stats %+v", fiveMinutes)
+	}
+	if fiveMinutes.P50 != 51*time.Millisecond || fiveMinutes.P90 != 91*time.Millisecond || fiveMinutes.P99 != 100*time.Millisecond {
+		t.Fatalf("unexpected 5m percentiles %s %s %s", fiveMinutes.P50, fiveMinutes.P90, fiveMinutes.P99)
+	}
+
+	if hour.Results != 105 || hour.Unavailable != 3 || hour.Flaps != 3 || hour.Outcomes[OutcomeTimeout] != 2 {
+		t.Fatalf("unexpected 1h stats %+v", hour)
+	}
+	if hour.Truncated {
+		t.Fatal("expected complete stats")
+	}
+
+	// Open or filtered UDP ports are available without a latency.
+	h.Record("c", Result{Time: ago(2 * time.Second), Outcome: OutcomeOpenFiltered, Latency: time.Second})
+	h.Record("c", Result{Time: ago(time.Second), Outcome: OutcomeOK, Latency: time.Millisecond})
+	if s, _ := h.Stats("c", time.Minute); s.Available != 2 || s.Flaps != 0 || s.MeanLatency != time.Millisecond {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+
+	// Only local errors.
+	h.Record("b", Result{Time: ago(time.Second), Outcome: OutcomeLocalError})
+	if s, _ := h.Stats("b", time.Minute); s.Availability != 0 || s.Excluded != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if targets := h.Targets(); len(targets) != 3 || targets[0] != "a" || targets[1] != "b" {
+		t.Fatalf("unexpected targets %v", targets)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4}
+	for p, expected := range map[int]time.Duration{0: 1, 25: 1, 26: 2, 50: 2, 99: 4, 100: 4} {
+		if v := percentile(sorted, p); v != expected {
+			t.Errorf("percentile(%d) = %d, expected %d", p, v, expected)
+		}
+	}
+}
+
+func TestObserver(t *testing.T) {
+	h := New()
+	c := tcpshaker.NewChecker(tcpshaker.WithObserver(h.Observer()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	if err := c.CheckAddr(addr, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	_ = c.CheckAddr(addr, time.Second)
+
+	results := h.Results(addr)
+	if len(results) != 2 || results[0].Outcome != OutcomeOK || results[1].Outcome != OutcomeConnectError {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if results[1].Errno != syscall.ECONNREFUSED || results[0].Time.IsZero() || results[0].Latency <= 0 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if s, _ := h.Stats(addr, time.Minute); s.Availability != 0.5 || s.Flaps != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
@@ -0,0 +1,140 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Stats contains the statistics of the results of a target within a window.
+type Stats struct {
+	Window time.Duration
+	// First and Last are the times of the first and the last result within the window.
+	First, Last time.Time
+	// Results is the number of results within the window.
+	Results int
+	// Available is the number of succeeded checks, including OutcomeOpenFiltered.
+	Available int
+	// Unavailable is the number of failed checks, except the ones Excluded.
+	Unavailable int
+	// Excluded is the number of checks failed due to local resources,
+	// which do not count against the target.
+	Excluded int
+	// Outcomes is the number of results by outcome.
+	Outcomes map[Outcome]int
+	// Availability is Available/(Available+Unavailable), zero if neither.
+	Availability float64
+	// MeanLatency, P50, P90 and P99 are the latency statistics of the checks of OutcomeOK.
+	MeanLatency, P50, P90, P99 time.Duration
+	// Flaps is the number of changes between available and unavailable.
+	Flaps int
+	// Truncated is true if results within the window were dropped due to the capacity.
+	Truncated bool
+}
+
+// statsJSON is the JSON representation of Stats, latencies are in milliseconds.
+type statsJSON struct {
+	Window        string          `json:"window"`
+	First         *time.Time      `json:"first,omitempty"`
+	Last          *time.Time      `json:"last,omitempty"`
+	Results       int             `json:"results"`
+	Available     int             `json:"available"`
+	Unavailable   int             `json:"unavailable"`
+	Excluded      int             `json:"excluded,omitempty"`
+	Outcomes      map[Outcome]int `json:"outcomes,omitempty"`
+	Availability  float64         `json:"availability"`
+	MeanLatencyMS float64         `json:"mean_latency_ms"`
+	P50MS         float64         `json:"p50_ms"`
+	P90MS         float64         `json:"p90_ms"`
+	P99MS         float64         `json:"p99_ms"`
+	Flaps         int             `json:"flaps"`
+	Truncated     bool            `json:"truncated,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Stats) MarshalJSON() ([]byte, error) {
+	v := statsJSON{
+		Window:        s.Window.String(),
+		Results:       s.Results,
+		Available:     s.Available,
+		Unavailable:   s.Unavailable,
+		Excluded:      s.Excluded,
+		Outcomes:      s.Outcomes,
+		Availability:  s.Availability,
+		MeanLatencyMS: ms(s.MeanLatency),
+		P50MS:         ms(s.P50),
+		P90MS:         ms(s.P90),
+		P99MS:         ms(s.P99),
+		Flaps:         s.Flaps,
+		Truncated:     s.Truncated,
+	}
+	if !s.First.IsZero() {
+		v.First, v.Last = &s.First, &s.Last
+	}
+	return json.Marshal(v)
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Report contains the statistics of all targets within every window of a History.
+type Report struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	Targets     []TargetReport `json:"targets"`
+}
+
+// TargetReport contains the statistics of a target.
+type TargetReport struct {
+	Target  string  `json:"target"`
+	Windows []Stats `json:"windows"`
+}
+
+// Report returns the statistics of all targets within every window.
+func (h *History) Report() *Report {
+	report := &Report{GeneratedAt: h.now(), Targets: []TargetReport{}}
+	for _, target := range h.Targets() {
+		report.Targets = append(report.Targets, TargetReport{Target: target, Windows: h.Summary(target)})
+	}
+	return report
+}
+
+// WriteJSON writes the Report as JSON to w.
+func (h *History) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(h.Report())
+}
+
+// csvHeader is the header of WriteCSV.
+var csvHeader = []string{"timestamp", "target", "outcome", "errno", "latency_ms"}
+
+// WriteCSV writes the kept results of all targets as CSV to w,
+// the targets in lexical order and the results of each the oldest first.
+func (h *History) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, target := range h.Targets() {
+		for _, r := range h.Results(target) {
+			errno := ""
+			if r.Errno != 0 {
+				errno = strconv.Itoa(int(r.Errno))
+			}
+			if err := cw.Write([]string{
+				r.Time.Format(time.RFC3339Nano),
+				target,
+				string(r.Outcome),
+				errno,
+				strconv.FormatFloat(ms(r.Latency), 'f', 3, 64),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,64 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWriteJSON(t *testing.T) {
+	h := newTestHistory(WithWindows(time.Minute))
+	h.Record("b", Result{Time: ago(time.Second), Outcome: OutcomeOK, Latency: 1500 * time.Microsecond})
+	h.Record("a", Result{Time: ago(2 * time.Minute), Outcome: OutcomeTimeout})
+
+	var buf bytes.Buffer
+	if err := h.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		GeneratedAt time.Time `json:"generated_at"`
+		Targets     []struct {
+			Target  string                   `json:"target"`
+			Windows []map[string]interface{} `json:"windows"`
+		} `json:"targets"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if !report.GeneratedAt.Equal(epoch) || len(report.Targets) != 2 || report.Targets[0].Target != "a" {
+		t.Fatalf("unexpected report %s", buf.String())
+	}
+	a, b := report.Targets[0].Windows[0], report.Targets[1].Windows[0]
+	if a["window"] != "1m0s" || a["results"] != 0.0 || a["first"] != nil {
+		t.Fatalf("unexpected stats of a %v", a)
+	}
+	if b["availability"] != 1.0 || b["p50_ms"] != 1.5 || b["mean_latency_ms"] != 1.5 {
+		t.Fatalf("unexpected stats of b %v", b)
+	}
+	if outcomes, _ := b["outcomes"].(map[string]interface{}); outcomes["ok"] != 1.0 {
+		t.Fatalf("unexpected outcomes of b %v", b["outcomes"])
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	h := newTestHistory()
+	h.Record("b", Result{Time: epoch, Outcome: OutcomeOK, Latency: 1500 * time.Microsecond})
+	h.Record("a", Result{Time: epoch, Outcome: OutcomeConnectError, Errno: syscall.ECONNREFUSED})
+
+	var buf bytes.Buffer
+	if err := h.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join([]string{
+		"timestamp,target,outcome,errno,latency_ms",
+		"2024-01-01T00:00:00Z,a,connect_error,111,0.000",
+		"2024-01-01T00:00:00Z,b,ok,,1.500",
+		"",
+	}, "\n")
+	if buf.String() != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+}
@@ -0,0 +1,206 @@
+// Package histogram implements a histogram in the style of HdrHistogram,
+// which records values with 3 significant figures of precision in constant
+// memory regardless of the number of values recorded.
+package histogram
+
+import (
+	"math"
+	"math/bits"
+)
+
+const (
+	// subBucketBits is the bits of the sub buckets, 2^11 sub buckets keep
+	// 3 significant figures.
+	subBucketBits      = 11
+	subBucketCount     = 1 << subBucketBits
+	subBucketHalfCount = subBucketCount / 2
+)
+
+// Histogram records non-negative values, values above the highest trackable
+// value are recorded as the highest one.
+//
+// The value range is split into buckets of exponentially growing widths,
+// each of which is split linearly into sub buckets.
+// NOTE: Histogram is not safe for concurrent use.
+type Histogram struct {
+	highest int64
+	counts  []uint64
+	total   uint64
+	min     int64
+	max     int64
+	sum     float64
+	sumSq   float64
+}
+
+// New creates a Histogram able to track values in [0, highest].
+func New(highest int64) *Histogram {
+	if highest < subBucketCount {
+		highest = subBucketCount
+	}
+	return &Histogram{
+		highest: highest,
+		counts:  make([]uint64, countsIndex(highest)+1),
+		min:     math.MaxInt64,
+	}
+}
+
+// countsIndex returns the index of the sub bucket of v.
+func countsIndex(v int64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	// v is in [2^(shift+subBucketBits-1), 2^(shift+subBucketBits))
+	shift := bits.Len64(uint64(v)) - subBucketBits
+	sub := int(v >> uint(shift))
+	return subBucketCount + (shift-1)*subBucketHalfCount + sub - subBucketHalfCount
+}
+
+// lowestValue returns the lowest value of the sub bucket at given index.
+func lowestValue(index int) int64 {
+	if index < subBucketCount {
+		return int64(index)
+	}
+	index -= subBucketCount
+	shift := index/subBucketHalfCount + 1
+	sub := index%subBucketHalfCount + subBucketHalfCount
+	return int64(sub) << uint(shift)
+}
+
+// highestValue returns the highest value of the sub bucket at given index.
+func highestValue(index int) int64 {
+	return lowestValue(index+1) - 1
+}
+
+// Record records the value v.
+func (h *Histogram) Record(v int64) {
+	if v < 0 {
+		v = 0
+	}
+	if v > h.highest {
+		v = h.highest
+	}
+	h.counts[countsIndex(v)]++
+	h.total++
+	if v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	f := float64(v)
+	h.sum += f
+	h.sumSq += f * f
+}
+
+// Merge adds all values recorded by other to h.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.total == 0 {
+		return
+	}
+	for i, count := range other.counts {
+		if count == 0 {
+			continue
+		}
+		v := lowestValue(i)
+		if v > h.highest {
+			v = h.highest
+		}
+		h.counts[countsIndex(v)] += count
+	}
+	h.total += other.total
+	h.min = min(h.min, other.min)
+	h.max = max(h.max, min(other.max, h.highest))
+	h.sum += other.sum
+	h.sumSq += other.sumSq
+}
+
+// Count returns the number of recorded values.
+func (h *Histogram) Count() uint64 { return h.total }
+
+// Min returns the minimum recorded value, 0 if nothing is recorded.
+func (h *Histogram) Min() int64 {
+	if h.total == 0 {
+		return 0
+	}
+	return h.min
+}
+
+// Max returns the maximum recorded value.
+func (h *Histogram) Max() int64 { return h.max }
+
+// Mean returns the mean of recorded values.
+func (h *Histogram) Mean() float64 {
+	if h.total == 0 {
+		return 0
+	}
+	return h.sum / float64(h.total)
+}
+
+// StdDev returns the population standard deviation of recorded values.
+func (h *Histogram) StdDev() float64 {
+	if h.total == 0 {
+		return 0
+	}
+	mean := h.Mean()
+	variance := h.sumSq/float64(h.total) - mean*mean
+	if variance < 0 {
+		return 0
+	}
+	return math.Sqrt(variance)
+}
+
+// ValueAtPercentile returns the value below or equal to which the given
+// percent(in [0, 100]) of recorded values fall.
+// The result is the highest value equivalent to the sub bucket found and
+// never exceeds the maximum recorded value.
+func (h *Histogram) ValueAtPercentile(percentile float64) int64 {
+	if h.total == 0 {
+		return 0
+	}
+	percentile = min(max(percentile, 0), 100)
+	target := uint64(math.Ceil(percentile / 100 * float64(h.total)))
+	if target == 0 {
+		target = 1
+	}
+	var seen uint64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= target {
+			return max(min(highestValue(i), h.max), h.Min())
+		}
+	}
+	return h.max
+}
+
+// Bar is a range of values in the distribution of a Histogram.
+type Bar struct {
+	From  int64
+	To    int64
+	Count uint64
+}
+
+// Distribution splits the range between the minimum and maximum recorded
+// values into n linear ranges and counts the values in each of them.
+func (h *Histogram) Distribution(n int) []Bar {
+	if h.total == 0 || n <= 0 {
+		return nil
+	}
+	lo, hi := h.Min(), h.Max()
+	width := (hi - lo + int64(n)) / int64(n)
+	if width == 0 {
+		width = 1
+	}
+	bars := make([]Bar, n)
+	for i := range bars {
+		bars[i].From = lo + int64(i)*width
+		bars[i].To = bars[i].From + width - 1
+	}
+	for i, count := range h.counts {
+		if count == 0 {
+			continue
+		}
+		v := max(min(lowestValue(i), hi), lo)
+		bars[min(int((v-lo)/width), n-1)].Count += count
+	}
+	return bars
+}
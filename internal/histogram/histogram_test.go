@@ -0,0 +1,106 @@
+package histogram
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestIndexRoundTrip(t *testing.T) {
+	for _, v := range []int64{0, 1, 2047, 2048, 2049, 4095, 4096, 123456, 1 << 40} {
+		i := countsIndex(v)
+		if lo, hi := lowestValue(i), highestValue(i); v < lo || v > hi {
+			t.Fatalf("%d is not in [%d, %d] of index %d", v, lo, hi, i)
+		}
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	h := New(1000)
+	if h.Count() != 0 || h.Min() != 0 || h.Max() != 0 || h.Mean() != 0 || h.StdDev() != 0 {
+		t.Fatal("empty histogram should report zeros")
+	}
+	if h.ValueAtPercentile(50) != 0 || h.Distribution(10) != nil {
+		t.Fatal("empty histogram should report zeros")
+	}
+}
+
+func TestStats(t *testing.T) {
+	h := New(3600e6)
+	for _, v := range []int64{2, 4, 4, 4, 5, 5, 7, 9} {
+		h.Record(v)
+	}
+	if h.Count() != 8 || h.Min() != 2 || h.Max() != 9 {
+		t.Fatalf("unexpected count/min/max: %d/%d/%d", h.Count(), h.Min(), h.Max())
+	}
+	if h.Mean() != 5 || h.StdDev() != 2 {
+		t.Fatalf("unexpected mean/stddev: %f/%f", h.Mean(), h.StdDev())
+	}
+	if p := h.ValueAtPercentile(50); p != 4 {
+		t.Fatalf("unexpected p50: %d", p)
+	}
+	if p := h.ValueAtPercentile(100); p != 9 {
+		t.Fatalf("unexpected p100: %d", p)
+	}
+}
+
+func TestPercentilePrecision(t *testing.T) {
+	const n = 100000
+	h := New(3600e6)
+	values := make([]int64, n)
+	r := rand.New(rand.NewSource(1))
+	for i := range values {
+		values[i] = int64(r.ExpFloat64() * 50000)
+		h.Record(values[i])
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	for _, p := range []float64{50, 90, 99, 99.9} {
+		expected := values[int(math.Ceil(p/100*n))-1]
+		got := h.ValueAtPercentile(p)
+		if diff := math.Abs(float64(got-expected)) / float64(expected); diff > 0.001 {
+			t.Fatalf("p%v: expected %d, got %d", p, expected, got)
+		}
+	}
+}
+
+func TestClamp(t *testing.T) {
+	h := New(10000)
+	h.Record(-1)
+	h.Record(1 << 40)
+	if h.Min() != 0 || h.Max() != h.highest {
+		t.Fatalf("values are not clamped: %d, %d", h.Min(), h.Max())
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b := New(1e6), New(1e6)
+	for i := int64(1); i <= 100; i++ {
+		a.Record(i)
+		b.Record(i + 100)
+	}
+	a.Merge(b)
+	if a.Count() != 200 || a.Min() != 1 || a.Max() != 200 {
+		t.Fatalf("unexpected count/min/max: %d/%d/%d", a.Count(), a.Min(), a.Max())
+	}
+	if p := a.ValueAtPercentile(50); p != 100 {
+		t.Fatalf("unexpected p50: %d", p)
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	h := New(1e6)
+	for i := int64(0); i < 100; i++ {
+		h.Record(i)
+	}
+	bars := h.Distribution(10)
+	if len(bars) != 10 {
+		t.Fatalf("expected 10 bars, got %d", len(bars))
+	}
+	for _, bar := range bars {
+		if bar.Count != 10 || bar.To-bar.From != 9 {
+			t.Fatalf("unexpected bar: %+v", bar)
+		}
+	}
+}
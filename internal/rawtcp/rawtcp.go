@@ -0,0 +1,148 @@
+// Package rawtcp builds and parses the TCP segments exchanged by the raw SYN
+// mode, which crafts the SYN and RST itself instead of asking the kernel to.
+package rawtcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+)
+
+// TCP flags.
+const (
+	FlagFIN = 1 << 0
+	FlagSYN = 1 << 1
+	FlagRST = 1 << 2
+	FlagPSH = 1 << 3
+	FlagACK = 1 << 4
+)
+
+const (
+	headerLen = 20
+	// synLen is the length of a SYN with the MSS option.
+	synLen = headerLen + 4
+
+	protocolTCP = 6
+)
+
+// defaultMSS is announced in SYNs, it is the MSS of a 1500 bytes MTU.
+const defaultMSS = 1460
+
+// Segment is a TCP segment without payload.
+type Segment struct {
+	SrcPort uint16
+	DstPort uint16
+	Seq     uint32
+	Ack     uint32
+	Flags   uint8
+	Window  uint16
+}
+
+// Is returns true if all the given flags are set.
+func (s *Segment) Is(flags uint8) bool {
+	return s.Flags&flags == flags
+}
+
+// Marshal encodes the segment with the checksum computed for given
+// source and destination addresses. The MSS option is included in SYNs.
+func (s *Segment) Marshal(src, dst netip.Addr) []byte {
+	length := headerLen
+	if s.Flags&FlagSYN != 0 {
+		length = synLen
+	}
+	b := make([]byte, length)
+	binary.BigEndian.PutUint16(b[0:], s.SrcPort)
+	binary.BigEndian.PutUint16(b[2:], s.DstPort)
+	binary.BigEndian.PutUint32(b[4:], s.Seq)
+	binary.BigEndian.PutUint32(b[8:], s.Ack)
+	b[12] = byte(length/4) << 4
+	b[13] = s.Flags
+	binary.BigEndian.PutUint16(b[14:], s.Window)
+	if length == synLen {
+		// kind 2, length 4
+		b[20], b[21] = 2, 4
+		binary.BigEndian.PutUint16(b[22:], defaultMSS)
+	}
+	binary.BigEndian.PutUint16(b[16:], Checksum(src, dst, b))
+	return b
+}
+
+// ErrTruncated indicates the packet is too short to be parsed.
+var ErrTruncated = errors.New("rawtcp: truncated packet")
+
+// ErrNotTCP indicates the IP packet does not carry TCP.
+var ErrNotTCP = errors.New("rawtcp: not a TCP packet")
+
+// Parse decodes the header of a TCP segment.
+func Parse(b []byte) (*Segment, error) {
+	if len(b) < headerLen {
+		return nil, ErrTruncated
+	}
+	return &Segment{
+		SrcPort: binary.BigEndian.Uint16(b[0:]),
+		DstPort: binary.BigEndian.Uint16(b[2:]),
+		Seq:     binary.BigEndian.Uint32(b[4:]),
+		Ack:     binary.BigEndian.Uint32(b[8:]),
+		Flags:   b[13],
+		Window:  binary.BigEndian.Uint16(b[14:]),
+	}, nil
+}
+
+// ParseIPv4 decodes an IPv4 packet carrying TCP as received from a raw
+// socket, returning its source and destination addresses and the segment.
+func ParseIPv4(b []byte) (src, dst netip.Addr, s *Segment, err error) {
+	if len(b) < 20 {
+		return src, dst, nil, ErrTruncated
+	}
+	ihl := int(b[0]&0x0f) * 4
+	if b[0]>>4 != 4 || ihl < 20 || len(b) < ihl {
+		return src, dst, nil, ErrTruncated
+	}
+	if b[9] != protocolTCP {
+		return src, dst, nil, ErrNotTCP
+	}
+	src = netip.AddrFrom4([4]byte(b[12:16]))
+	dst = netip.AddrFrom4([4]byte(b[16:20]))
+	s, err = Parse(b[ihl:])
+	return src, dst, s, err
+}
+
+// ParseIPv6 decodes an IPv6 packet carrying TCP right after the fixed header,
+// returning its source and destination addresses and the segment.
+// Packets with extension headers are reported as ErrNotTCP.
+func ParseIPv6(b []byte) (src, dst netip.Addr, s *Segment, err error) {
+	if len(b) < 40 || b[0]>>4 != 6 {
+		return src, dst, nil, ErrTruncated
+	}
+	if b[6] != protocolTCP {
+		return src, dst, nil, ErrNotTCP
+	}
+	src = netip.AddrFrom16([16]byte(b[8:24]))
+	dst = netip.AddrFrom16([16]byte(b[24:40]))
+	s, err = Parse(b[40:])
+	return src, dst, s, err
+}
+
+// Checksum computes the TCP checksum of segment b over the pseudo header of
+// given addresses, the checksum field of b must be zero.
+func Checksum(src, dst netip.Addr, b []byte) uint16 {
+	var sum uint32
+	add := func(p []byte) {
+		for len(p) >= 2 {
+			sum += uint32(binary.BigEndian.Uint16(p))
+			p = p[2:]
+		}
+		if len(p) == 1 {
+			sum += uint32(p[0]) << 8
+		}
+	}
+	srcBytes, dstBytes := src.AsSlice(), dst.AsSlice()
+	add(srcBytes)
+	add(dstBytes)
+	sum += protocolTCP + uint32(len(b))
+	add(b)
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
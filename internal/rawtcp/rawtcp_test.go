@@ -0,0 +1,96 @@
+package rawtcp
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestMarshalParse(t *testing.T) {
+	src, dst := netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2")
+	syn := Segment{SrcPort: 40000, DstPort: 80, Seq: 0xdeadbeef, Flags: FlagSYN, Window: 64240}
+	b := syn.Marshal(src, dst)
+	if len(b) != synLen {
+		t.Fatalf("expected %d bytes, got %d", synLen, len(b))
+	}
+	// A valid checksum sums to zero.
+	if sum := Checksum(src, dst, b); sum != 0 {
+		t.Fatalf("invalid checksum: %#x", sum)
+	}
+	s, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *s != syn {
+		t.Fatalf("expected %+v, got %+v", syn, *s)
+	}
+	if !s.Is(FlagSYN) || s.Is(FlagSYN|FlagACK) {
+		t.Fatalf("unexpected flags: %#x", s.Flags)
+	}
+
+	rst := Segment{SrcPort: 40000, DstPort: 80, Seq: 1, Flags: FlagRST}
+	if b := rst.Marshal(src, dst); len(b) != headerLen || Checksum(src, dst, b) != 0 {
+		t.Fatalf("invalid RST: %x", b)
+	}
+}
+
+func TestChecksumIPv6(t *testing.T) {
+	src, dst := netip.MustParseAddr("::1"), netip.MustParseAddr("::1")
+	syn := Segment{SrcPort: 1, DstPort: 2, Seq: 3, Flags: FlagSYN}
+	if sum := Checksum(src, dst, syn.Marshal(src, dst)); sum != 0 {
+		t.Fatalf("invalid checksum: %#x", sum)
+	}
+}
+
+func TestParseIPv4(t *testing.T) {
+	src, dst := netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("127.0.0.2")
+	seg := Segment{SrcPort: 80, DstPort: 40000, Seq: 7, Ack: 8, Flags: FlagSYN | FlagACK}
+	tcp := seg.Marshal(src, dst)
+	ip := make([]byte, 20, 20+len(tcp))
+	ip[0] = 0x45
+	ip[9] = protocolTCP
+	copy(ip[12:], src.AsSlice())
+	copy(ip[16:], dst.AsSlice())
+	pkt := append(ip, tcp...)
+
+	gotSrc, gotDst, s, err := ParseIPv4(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotSrc != src || gotDst != dst || *s != seg {
+		t.Fatalf("unexpected result: %s %s %+v", gotSrc, gotDst, *s)
+	}
+
+	pkt[9] = 17
+	if _, _, _, err := ParseIPv4(pkt); err != ErrNotTCP {
+		t.Fatalf("expected ErrNotTCP, got %v", err)
+	}
+	pkt[9] = protocolTCP
+	if _, _, _, err := ParseIPv4(pkt[:30]); err != ErrTruncated {
+		t.Fatalf("expected ErrTruncated, got %v", err)
+	}
+}
+
+func TestParseIPv6(t *testing.T) {
+	src, dst := netip.MustParseAddr("::1"), netip.MustParseAddr("fe80::1")
+	seg := Segment{SrcPort: 80, DstPort: 40000, Seq: 7, Ack: 8, Flags: FlagRST | FlagACK}
+	tcp := seg.Marshal(src, dst)
+	ip := make([]byte, 40, 40+len(tcp))
+	ip[0] = 0x60
+	ip[6] = protocolTCP
+	copy(ip[8:], src.AsSlice())
+	copy(ip[24:], dst.AsSlice())
+	pkt := append(ip, tcp...)
+
+	gotSrc, gotDst, s, err := ParseIPv6(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotSrc != src || gotDst != dst || *s != seg {
+		t.Fatalf("unexpected result: %s %s %+v", gotSrc, gotDst, *s)
+	}
+
+	pkt[6] = 0 // hop-by-hop options
+	if _, _, _, err := ParseIPv6(pkt); err != ErrNotTCP {
+		t.Fatalf("expected ErrNotTCP, got %v", err)
+	}
+}
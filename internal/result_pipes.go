@@ -4,4 +4,5 @@ type ResultPipes interface {
 	PopResultPipe(int) (chan error, bool)
 	DeRegisterResultPipe(int)
 	RegisterResultPipe(int, chan error)
+	Range(func(int, chan error) bool)
 }
@@ -29,3 +29,9 @@ func (r *resultPipesSyncMap) RegisterResultPipe(fd int, pipe chan error) {
 	// NOTE: the pipe should have been put back if c.fdResultPipes[fd] exists.
 	r.Store(fd, pipe)
 }
+
+func (r *resultPipesSyncMap) Range(f func(int, chan error) bool) {
+	r.Map.Range(func(fd, pipe interface{}) bool {
+		return f(fd.(int), pipe.(chan error))
+	})
+}
@@ -0,0 +1,96 @@
+package tcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// Mode is the technique used by a check to probe the target.
+type Mode int
+
+const (
+	// ModeHalfOpen aborts the handshake before the final ACK is sent, which is
+	// the default. See WithZeroLinger and WithRawSYN for the details.
+	ModeHalfOpen Mode = iota
+	// ModeConnect completes the handshake and closes the connection gracefully with a FIN,
+	// for the targets which log or penalize RSTs not acknowledging their SYN-ACK.
+	ModeConnect
+	// ModeConnectReset completes the handshake and resets the connection with an RST.
+	ModeConnectReset
+	// ModeUDP checks a UDP port by sending the payload set by WithUDPPayload,
+	// see Checker.CheckUDP.
+	ModeUDP
+	// ModeSCTP checks an SCTP port with a non-blocking connect of an SCTP socket,
+	// the association is aborted with an ABORT once connect succeeds.
+	// A closed port is reported as ErrConnect wrapping ECONNREFUSED.
+	// NOTE: it is not a half-open check, the kernel does not allow aborting
+	// after the INIT-ACK, so the association is established before the ABORT.
+	ModeSCTP
+	// ModeBacklog is ModeHalfOpen with SYN retransmissions inspected to tell
+	// an overloaded listener from a dead host, which is probed on port 1 once
+	// all the SYNs are dropped, see ErrSYNDropped and WithSYNRetries.
+	ModeBacklog
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeHalfOpen:
+		return "half-open"
+	case ModeConnect:
+		return "connect"
+	case ModeConnectReset:
+		return "connect-reset"
+	case ModeUDP:
+		return "udp"
+	case ModeSCTP:
+		return "sctp"
+	case ModeBacklog:
+		return "backlog"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// zeroLinger returns whether linger should be set to zero in mode,
+// halfOpen is the setting of ModeHalfOpen.
+func (m Mode) zeroLinger(halfOpen bool) bool {
+	switch m {
+	case ModeConnect:
+		return false
+	case ModeConnectReset, ModeSCTP:
+		return true
+	}
+	return halfOpen
+}
+
+// modeKey is the context key of the mode of a check.
+type modeKey struct{}
+
+// contextWithMode returns a copy of ctx carrying the mode of a check.
+func contextWithMode(ctx context.Context, mode Mode) context.Context {
+	return context.WithValue(ctx, modeKey{}, mode)
+}
+
+// ModeFromContext returns the mode of the check, it is available in the
+// contexts passed to the methods of Observer.
+func ModeFromContext(ctx context.Context) (Mode, bool) {
+	mode, ok := ctx.Value(modeKey{}).(Mode)
+	return mode, ok
+}
+
+// networkMode returns the mode of a check of network, mode is the one set by
+// WithMode, which is kept for "tcp" unless it is not a TCP mode.
+func networkMode(network string, mode Mode) (Mode, error) {
+	switch network {
+	case "tcp":
+		if mode == ModeUDP || mode == ModeSCTP {
+			return ModeHalfOpen, nil
+		}
+		return mode, nil
+	case "udp":
+		return ModeUDP, nil
+	case "sctp":
+		return ModeSCTP, nil
+	}
+	return mode, net.UnknownNetworkError(network)
+}
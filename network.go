@@ -0,0 +1,15 @@
+package tcp
+
+import "context"
+
+// CheckNetwork is like CheckAddrContext but the protocol is chosen by network
+// as in net.Dial: "tcp" is checked in the mode set by WithMode, or ModeHalfOpen
+// if it is ModeUDP or ModeSCTP, "udp" in ModeUDP and "sctp" in ModeSCTP.
+// net.UnknownNetworkError is returned for any other network.
+func (c *Checker) CheckNetwork(ctx context.Context, network, addr string) error {
+	mode, err := networkMode(network, c.mode)
+	if err != nil {
+		return err
+	}
+	return c.checkAddr(ctx, addr, mode, c.zeroLinger)
+}
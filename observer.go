@@ -0,0 +1,107 @@
+package tcp
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Observer is notified about what the Checker is doing internally, it is
+// meant for building metrics, tracing and logging integrations.
+//
+// Methods taking a context are called in the goroutine performing the check,
+// the context returned by OnCheckStart is passed to the rest of them so that
+// observers could carry per-check state. OnPollerEvent and OnLoopError are
+// called in the goroutine running CheckingLoop.
+//
+// NOTE: Observers are called synchronously, they should never block.
+// Embed NopObserver to implement only a part of the methods.
+type Observer interface {
+	// OnCheckStart is called when a check of addr begins.
+	OnCheckStart(ctx context.Context, addr string) context.Context
+	// OnResolved is called when the address is resolved.
+	OnResolved(ctx context.Context, addr *net.TCPAddr)
+	// OnSocketCreated is called when the socket of a check is created.
+	OnSocketCreated(ctx context.Context, fd int)
+	// OnConnectIssued is called right after the connect syscall,
+	// err is the error returned immediately by connect if any.
+	OnConnectIssued(ctx context.Context, fd int, err error)
+	// OnPollerEvent is called for every event returned by the poller,
+	// including the ones whose check is already gone.
+	OnPollerEvent(fd int, err error)
+	// OnCheckDone is called with the result of a check.
+	OnCheckDone(ctx context.Context, err error, elapsed time.Duration)
+	// OnLoopError is called when CheckingLoop stops due to a fatal error, the
+	// errors recovered by the Supervisor are not reported, see Checker.LoopStatus.
+	OnLoopError(err error)
+}
+
+// NopObserver is an Observer that does nothing.
+type NopObserver struct{}
+
+// OnCheckStart implements Observer.
+func (NopObserver) OnCheckStart(ctx context.Context, _ string) context.Context { return ctx }
+
+// OnResolved implements Observer.
+func (NopObserver) OnResolved(context.Context, *net.TCPAddr) {}
+
+// OnSocketCreated implements Observer.
+func (NopObserver) OnSocketCreated(context.Context, int) {}
+
+// OnConnectIssued implements Observer.
+func (NopObserver) OnConnectIssued(context.Context, int, error) {}
+
+// OnPollerEvent implements Observer.
+func (NopObserver) OnPollerEvent(int, error) {}
+
+// OnCheckDone implements Observer.
+func (NopObserver) OnCheckDone(context.Context, error, time.Duration) {}
+
+// OnLoopError implements Observer.
+func (NopObserver) OnLoopError(error) {}
+
+// multiObserver dispatches to multiple observers in order.
+type multiObserver []Observer
+
+func (m multiObserver) OnCheckStart(ctx context.Context, addr string) context.Context {
+	for _, o := range m {
+		ctx = o.OnCheckStart(ctx, addr)
+	}
+	return ctx
+}
+
+func (m multiObserver) OnResolved(ctx context.Context, addr *net.TCPAddr) {
+	for _, o := range m {
+		o.OnResolved(ctx, addr)
+	}
+}
+
+func (m multiObserver) OnSocketCreated(ctx context.Context, fd int) {
+	for _, o := range m {
+		o.OnSocketCreated(ctx, fd)
+	}
+}
+
+func (m multiObserver) OnConnectIssued(ctx context.Context, fd int, err error) {
+	for _, o := range m {
+		o.OnConnectIssued(ctx, fd, err)
+	}
+}
+
+func (m multiObserver) OnPollerEvent(fd int, err error) {
+	for _, o := range m {
+		o.OnPollerEvent(fd, err)
+	}
+}
+
+func (m multiObserver) OnCheckDone(ctx context.Context, err error, elapsed time.Duration) {
+	for _, o := range m {
+		o.OnCheckDone(ctx, err, elapsed)
+	}
+}
+
+func (m multiObserver) OnLoopError(err error) {
+	for _, o := range m {
+		o.OnLoopError(err)
+	}
+}
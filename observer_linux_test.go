@@ -0,0 +1,105 @@
+package tcp
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingObserver struct {
+	NopObserver
+	mu        sync.Mutex
+	calls     []string
+	fd        int
+	pollerFds []int
+	resolved  *net.TCPAddr
+	doneErr   error
+}
+
+func (o *recordingObserver) record(call string) {
+	o.mu.Lock()
+	o.calls = append(o.calls, call)
+	o.mu.Unlock()
+}
+
+func (o *recordingObserver) OnCheckStart(ctx context.Context, addr string) context.Context {
+	o.record("start")
+	return ctx
+}
+
+func (o *recordingObserver) OnResolved(ctx context.Context, addr *net.TCPAddr) {
+	o.record("resolved")
+	o.resolved = addr
+}
+
+func (o *recordingObserver) OnSocketCreated(ctx context.Context, fd int) {
+	o.record("socket")
+	o.fd = fd
+}
+
+func (o *recordingObserver) OnConnectIssued(ctx context.Context, fd int, err error) {
+	o.record("connect")
+}
+
+func (o *recordingObserver) OnPollerEvent(fd int, err error) {
+	o.mu.Lock()
+	o.pollerFds = append(o.pollerFds, fd)
+	o.mu.Unlock()
+}
+
+func (o *recordingObserver) OnCheckDone(ctx context.Context, err error, elapsed time.Duration) {
+	o.record("done")
+	o.doneErr = err
+}
+
+func TestObserver(t *testing.T) {
+	obs := &recordingObserver{}
+	c := NewChecker(WithObserver(obs))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	addr, stop := StartTestServer()
+	defer stop()
+
+	err := c.CheckAddr(addr, time.Second)
+	assert(t, err == nil)
+
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+	expected := []string{"start", "resolved", "socket", "connect", "done"}
+	assert(t, len(obs.calls) == len(expected))
+	for i := range expected {
+		assert(t, obs.calls[i] == expected[i])
+	}
+	assert(t, obs.resolved != nil && obs.resolved.String() == addr)
+	assert(t, obs.doneErr == nil)
+	assert(t, len(obs.pollerFds) == 1 && obs.pollerFds[0] == obs.fd)
+}
+
+func TestObserverResolveError(t *testing.T) {
+	obs := &recordingObserver{}
+	c := NewChecker(WithObserver(obs))
+
+	err := c.CheckAddr("127.0.0.1", time.Second)
+	assert(t, err != nil)
+	assert(t, len(obs.calls) == 2)
+	assert(t, obs.calls[0] == "start" && obs.calls[1] == "done")
+	assert(t, obs.doneErr == err)
+}
+
+func TestWithObserverMultiple(t *testing.T) {
+	first, second := &recordingObserver{}, &recordingObserver{}
+	conf := newConfig(WithObserver(first), WithObserver(nil), WithObserver(second))
+
+	conf.observer.OnLoopError(ErrTimeout)
+	conf.observer.OnCheckDone(context.Background(), ErrTimeout, 0)
+	assert(t, first.doneErr == ErrTimeout)
+	assert(t, second.doneErr == ErrTimeout)
+}
@@ -0,0 +1,147 @@
+package tcp
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Option configures a Checker, see NewChecker.
+type Option func(*config)
+
+// config contains the options shared by all implementations of Checker.
+type config struct {
+	zeroLinger bool
+	observer   Observer
+	logger     *slog.Logger
+	supervisor *Supervisor
+	rawSYN     bool
+	mode       Mode
+	udpPayload []byte
+	synRetries int
+	sourcePool *SourcePool
+	localStats *localStats
+}
+
+// debugEnabled returns whether debug logs are enabled, it guards the debug
+// logs on the path of every check so that their attributes are not built in vain.
+func (c *config) debugEnabled(ctx context.Context) bool {
+	return c.log().Enabled(ctx, slog.LevelDebug)
+}
+
+// log returns the logger set by WithLogger, or slog.Default() at the time of
+// the call so that a later slog.SetDefault takes effect.
+func (c *config) log() *slog.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	return slog.Default()
+}
+
+func newConfig(opts ...Option) config {
+	conf := config{
+		zeroLinger: true,
+		observer:   NopObserver{},
+		synRetries: defaultSYNRetries,
+		localStats: &localStats{},
+	}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return conf
+}
+
+// WithZeroLinger sets whether linger should be set to zero for every check
+// made by the Checker. It is enabled by default.
+// Without zero linger the socket is closed with a FIN instead of an RST,
+// which acknowledges the SYN-ACK and thus completes the handshake.
+func WithZeroLinger(zeroLinger bool) Option {
+	return func(c *config) {
+		c.zeroLinger = zeroLinger
+	}
+}
+
+// WithMode sets the Mode used by checks unless another one is given to
+// CheckAddrMode, ModeHalfOpen is used by default.
+func WithMode(mode Mode) Option {
+	return func(c *config) {
+		c.mode = mode
+	}
+}
+
+// WithUDPPayload sets the payload sent by the checks in ModeUDP,
+// an empty datagram is sent by default.
+func WithUDPPayload(payload []byte) Option {
+	return func(c *config) {
+		c.udpPayload = payload
+	}
+}
+
+// defaultSYNRetries makes the checks in ModeBacklog give up 7 seconds after
+// the first SYN with the initial RTO of 1 second.
+const defaultSYNRetries = 2
+
+// WithSYNRetries sets the number of SYN retransmissions(TCP_SYNCNT) of the
+// checks in ModeBacklog before the kernel gives up, 2 by default.
+// The retransmissions are sent 1, 3, 7... seconds after the first SYN,
+// so the timeout of the checks should be long enough to allow some of them.
+// It is a no-op on non-Linux platforms.
+func WithSYNRetries(retries int) Option {
+	return func(c *config) {
+		c.synRetries = retries
+	}
+}
+
+// WithObserver registers an Observer to be notified about the internals of
+// the Checker. It could be given more than once, observers are called in the
+// order they are registered.
+func WithObserver(observer Observer) Option {
+	return func(c *config) {
+		if observer == nil {
+			return
+		}
+		if _, isNop := c.observer.(NopObserver); isNop {
+			c.observer = observer
+			return
+		}
+		if m, ok := c.observer.(multiObserver); ok {
+			c.observer = append(m, observer)
+			return
+		}
+		c.observer = multiObserver{c.observer, observer}
+	}
+}
+
+// WithLogger sets the logger used by the Checker, slog.Default() is used by
+// default, it is resolved on every log so slog.SetDefault applies to existing Checkers.
+// Details like socket setup and poller errors are logged at debug level.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		if logger != nil {
+			c.logger = logger
+		}
+	}
+}
+
+// WithSupervisor makes CheckingLoop recreate the poller with exponential
+// backoff instead of returning when a fatal error occurs during polling.
+// Pending checks are registered to the new poller, the ones failed to be
+// registered get the error. See Checker.LoopStatus for the restarts.
+// NOTE: This option has no effect on non-Linux platforms.
+func WithSupervisor(supervisor Supervisor) Option {
+	return func(c *config) {
+		c.supervisor = supervisor.withDefaults()
+	}
+}
+
+// WithRawSYN makes the Checker craft the SYN with a raw socket, match the
+// SYN-ACK or RST by sequence number and abort with an RST of its own, so the
+// kernel never considers the connection established. Unlike the default
+// mode, which relies on the delayed ACK of the kernel, the handshake is never
+// completed. CheckingLoop fails to start without CAP_NET_RAW.
+// Only the checks in ModeHalfOpen are affected.
+// NOTE: This option has no effect on non-Linux platforms.
+func WithRawSYN(enabled bool) Option {
+	return func(c *config) {
+		c.rawSYN = enabled
+	}
+}
@@ -0,0 +1,160 @@
+// Package otel provides an OpenTelemetry tracing integration for tcp-shaker.
+//
+// Every check made by a Checker with the Observer of this package registered
+// results in a span, with events for address resolution, connect issuing and
+// the outcome of the handshake(SYN-ACK, RST or timeout).
+//
+// The span is a child of the span found in the ctx passed to
+// Checker.CheckAddrContext if any.
+package otel
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name of the tracer.
+const ScopeName = "github.com/tevino/tcp-shaker/otel"
+
+// SpanName is the name of the span created for each check.
+const SpanName = "tcpshaker.check"
+
+// Attribute keys, see the semantic conventions of OpenTelemetry.
+const (
+	KeyPeerName    = attribute.Key("net.peer.name")
+	KeyPeerPort    = attribute.Key("net.peer.port")
+	KeyPeerAddr    = attribute.Key("network.peer.address")
+	KeyNetworkType = attribute.Key("network.type")
+	KeyTransport   = attribute.Key("network.transport")
+	KeyOutcome     = attribute.Key("tcpshaker.outcome")
+)
+
+// Names of the span events.
+const (
+	EventResolved      = "resolved"
+	EventConnectIssued = "connect issued"
+	EventSynAck        = "syn-ack"
+	EventRST           = "rst"
+	EventTimeout       = "timeout"
+)
+
+// Option configures an Observer.
+type Option func(*Observer)
+
+// WithTracerProvider sets the TracerProvider used to create the tracer,
+// the global TracerProvider is used by default.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *Observer) {
+		o.tracer = tp.Tracer(ScopeName)
+	}
+}
+
+// Observer implements tcpshaker.Observer by creating a span for each check.
+type Observer struct {
+	tcpshaker.NopObserver
+	tracer trace.Tracer
+}
+
+// NewObserver creates an Observer, register it with tcpshaker.WithObserver.
+func NewObserver(opts ...Option) *Observer {
+	o := &Observer{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.tracer == nil {
+		o.tracer = otel.GetTracerProvider().Tracer(ScopeName)
+	}
+	return o
+}
+
+// OnCheckStart implements tcpshaker.Observer.
+func (o *Observer) OnCheckStart(ctx context.Context, addr string) context.Context {
+	if name, ok := tcpshaker.ParseUnixAddr(addr); ok {
+		ctx, _ = o.tracer.Start(ctx, SpanName,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(KeyTransport.String("unix"), KeyPeerName.String(name)),
+		)
+		return ctx
+	}
+	attrs := []attribute.KeyValue{KeyTransport.String(transport(ctx))}
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		attrs = append(attrs, KeyPeerName.String(host))
+		if p, err := strconv.Atoi(port); err == nil {
+			attrs = append(attrs, KeyPeerPort.Int(p))
+		}
+	}
+	ctx, _ = o.tracer.Start(ctx, SpanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx
+}
+
+// transport returns the network.transport of the mode of the check.
+func transport(ctx context.Context) string {
+	mode, _ := tcpshaker.ModeFromContext(ctx)
+	switch mode {
+	case tcpshaker.ModeUDP:
+		return "udp"
+	case tcpshaker.ModeSCTP:
+		return "sctp"
+	}
+	return "tcp"
+}
+
+// OnResolved implements tcpshaker.Observer.
+func (o *Observer) OnResolved(ctx context.Context, addr *net.TCPAddr) {
+	if addr == nil {
+		return
+	}
+	networkType := "ipv6"
+	if addr.IP.To4() != nil {
+		networkType = "ipv4"
+	}
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(KeyNetworkType.String(networkType), KeyPeerAddr.String(addr.IP.String()))
+	span.AddEvent(EventResolved)
+}
+
+// OnConnectIssued implements tcpshaker.Observer.
+func (o *Observer) OnConnectIssued(ctx context.Context, fd int, err error) {
+	span := trace.SpanFromContext(ctx)
+	if err != nil {
+		span.AddEvent(EventConnectIssued, trace.WithAttributes(attribute.String("error", err.Error())))
+		return
+	}
+	span.AddEvent(EventConnectIssued)
+}
+
+// OnCheckDone implements tcpshaker.Observer.
+func (o *Observer) OnCheckDone(ctx context.Context, err error, _ time.Duration) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	switch {
+	case err == nil:
+		span.AddEvent(EventSynAck)
+		span.SetAttributes(KeyOutcome.String("open"))
+		return
+	case errors.Is(err, tcpshaker.ErrTimeout):
+		span.AddEvent(EventTimeout)
+		span.SetAttributes(KeyOutcome.String("timeout"))
+	case errors.Is(err, syscall.ECONNREFUSED):
+		span.AddEvent(EventRST)
+		span.SetAttributes(KeyOutcome.String("refused"))
+	default:
+		span.SetAttributes(KeyOutcome.String("error"))
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,179 @@
+package otel
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestChecker(t *testing.T) (*tcpshaker.Checker, *tracetest.InMemoryExporter, *sdktrace.TracerProvider) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	c := tcpshaker.NewChecker(tcpshaker.WithObserver(NewObserver(WithTracerProvider(tp))))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+	return c, exporter, tp
+}
+
+func listen(t *testing.T) (string, int) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	return l.Addr().String(), l.Addr().(*net.TCPAddr).Port
+}
+
+func attrValue(attrs []attribute.KeyValue, key attribute.Key) (attribute.Value, bool) {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func eventNames(span tracetest.SpanStub) []string {
+	var names []string
+	for _, e := range span.Events {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSpanSucceeded(t *testing.T) {
+	c, exporter, tp := newTestChecker(t)
+	addr, port := listen(t)
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := c.CheckAddrContext(ctx, addr); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != SpanName {
+		t.Fatalf("unexpected span name %q", span.Name)
+	}
+	if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatal("trace context is not propagated")
+	}
+	if v, _ := attrValue(span.Attributes, KeyPeerName); v.AsString() != "127.0.0.1" {
+		t.Fatalf("unexpected %s: %q", KeyPeerName, v.AsString())
+	}
+	if v, _ := attrValue(span.Attributes, KeyPeerPort); v.AsInt64() != int64(port) {
+		t.Fatalf("unexpected %s: %d, expected %s", KeyPeerPort, v.AsInt64(), strconv.Itoa(port))
+	}
+	if v, _ := attrValue(span.Attributes, KeyNetworkType); v.AsString() != "ipv4" {
+		t.Fatalf("unexpected %s: %q", KeyNetworkType, v.AsString())
+	}
+	expected := []string{EventResolved, EventConnectIssued, EventSynAck}
+	if names := eventNames(span); !equal(names, expected) {
+		t.Fatalf("expected events %v, got %v", expected, names)
+	}
+	if span.Status.Code == codes.Error {
+		t.Fatal("unexpected error status")
+	}
+}
+
+func TestSpanRefused(t *testing.T) {
+	c, exporter, _ := newTestChecker(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	if err := c.CheckAddr(addr, time.Second); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	names := eventNames(span)
+	if len(names) < 2 || names[len(names)-1] != "exception" {
+		t.Fatalf("error not recorded: %v", names)
+	}
+	if names[len(names)-2] != EventRST {
+		t.Fatalf("expected event %s, got %v", EventRST, names)
+	}
+	if span.Status.Code != codes.Error {
+		t.Fatal("expected error status")
+	}
+}
+
+func TestSpanTimeout(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	o := NewObserver(WithTracerProvider(tp))
+
+	ctx := o.OnCheckStart(context.Background(), "example.com:80")
+	o.OnCheckDone(ctx, tcpshaker.ErrTimeout, time.Second)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if v, _ := attrValue(spans[0].Attributes, KeyOutcome); v.AsString() != "timeout" {
+		t.Fatalf("unexpected %s: %q", KeyOutcome, v.AsString())
+	}
+	if names := eventNames(spans[0]); len(names) == 0 || names[0] != EventTimeout {
+		t.Fatalf("expected event %s, got %v", EventTimeout, names)
+	}
+}
+
+func TestSpanTransport(t *testing.T) {
+	c, exporter, _ := newTestChecker(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	addr, _ := listen(t)
+	_ = c.CheckAddrContext(ctx, addr)
+	_ = c.CheckUDP(ctx, "127.0.0.1:9", nil)
+	_ = c.CheckAddrContext(ctx, "unix:@tcp-shaker-otel-test-missing")
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	for i, expected := range []string{"tcp", "udp", "unix"} {
+		if v, _ := attrValue(spans[i].Attributes, KeyTransport); v.AsString() != expected {
+			t.Errorf("unexpected %s of span %d: %q, expected %q", KeyTransport, i, v.AsString(), expected)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package probe
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+)
+
+// HTTP sends a request and expects the status code of the response to be in
+// [MinStatus, MaxStatus], which is [200, 399] by default.
+type HTTP struct {
+	// Method is GET by default.
+	Method string
+	// Path is "/" by default.
+	Path string
+	// Host is the host of the target address by default, see Target.
+	Host      string
+	MinStatus int
+	MaxStatus int
+}
+
+// Name implements Probe.
+func (h *HTTP) Name() string { return "http" }
+
+// Probe implements Probe.
+func (h *HTTP) Probe(ctx context.Context, conn net.Conn) error {
+	method, path, host := h.Method, h.Path, h.Host
+	if method == "" {
+		method = http.MethodGet
+	}
+	if path == "" {
+		path = "/"
+	}
+	if host == "" {
+		host = Target(ctx)
+		if h, port, err := net.SplitHostPort(host); err == nil && port == "80" {
+			host = h
+		}
+	}
+	if host == "" {
+		host = conn.RemoteAddr().String()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://"+host+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Close = true
+	req.Header.Set("User-Agent", "tcp-shaker")
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	minStatus, maxStatus := h.MinStatus, h.MaxStatus
+	if minStatus == 0 && maxStatus == 0 {
+		minStatus, maxStatus = 200, 399
+	}
+	if resp.StatusCode < minStatus || resp.StatusCode > maxStatus {
+		return fmt.Errorf("%w: status %s", ErrUnexpectedResponse, resp.Status)
+	}
+	return nil
+}
+
+// Redis sends PING and expects PONG, an error requiring authentication is
+// accepted as well since the server is serving.
+func Redis() Probe {
+	return &Expect{
+		ProbeName: "redis",
+		Send:      []byte("*1\r\n$4\r\nPING\r\n"),
+		Match:     regexp.MustCompile(`^(\+PONG|-NOAUTH)`),
+	}
+}
+
+// Banner expects the greeting sent by the server upon connecting to match pattern.
+func Banner(name string, pattern *regexp.Regexp) Probe {
+	return &Expect{ProbeName: name, Match: pattern}
+}
+
+// SMTP expects a "220" greeting from an SMTP server.
+func SMTP() Probe {
+	return Banner("smtp", regexp.MustCompile(`^220[ -]`))
+}
+
+// FTP expects a "220" greeting from an FTP server.
+func FTP() Probe {
+	return Banner("ftp", regexp.MustCompile(`^220[ -]`))
+}
+
+// MySQL expects the initial handshake packet of protocol version 10 from a
+// MySQL or MariaDB server, an error packet e.g. "Host is not allowed" fails.
+type MySQL struct{}
+
+// Name implements Probe.
+func (MySQL) Name() string { return "mysql" }
+
+// Probe implements Probe.
+func (MySQL) Probe(ctx context.Context, conn net.Conn) error {
+	// 3 bytes payload length, 1 byte sequence id
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	if length == 0 || length > defaultReadLimit {
+		return fmt.Errorf("%w: packet length %d", ErrUnexpectedResponse, length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return err
+	}
+	switch payload[0] {
+	case 0x0a:
+		return nil
+	case 0xff:
+		// 0xff, 2 bytes error code, message
+		if len(payload) >= 3 {
+			code := binary.LittleEndian.Uint16(payload[1:])
+			return fmt.Errorf("%w: error %d: %s", ErrUnexpectedResponse, code, truncate(payload[3:]))
+		}
+	}
+	return fmt.Errorf("%w: protocol version %d", ErrUnexpectedResponse, payload[0])
+}
+
+// sslRequestCode is the request code of the SSLRequest of PostgreSQL.
+const sslRequestCode = 80877103
+
+// PostgreSQL sends an SSLRequest and expects 'S' or 'N' telling whether
+// SSL is supported, no credentials are needed.
+func PostgreSQL() Probe {
+	req := make([]byte, 8)
+	binary.BigEndian.PutUint32(req, 8)
+	binary.BigEndian.PutUint32(req[4:], sslRequestCode)
+	return &Expect{
+		ProbeName: "postgresql",
+		Send:      req,
+		Match:     regexp.MustCompile(`^[SN]`),
+		ReadLimit: 1,
+	}
+}
+
+// Builtin returns the built-in Probe of given name, which is one of http,
+// https, tls, redis, mysql, smtp, ftp and postgresql.
+// nil is returned for unknown names.
+func Builtin(name string) Probe {
+	switch name {
+	case "http":
+		return &HTTP{}
+	case "https":
+		return &TLS{NextProtos: []string{"http/1.1"}, Next: &HTTP{}}
+	case "tls":
+		return &TLS{}
+	case "redis":
+		return Redis()
+	case "mysql":
+		return MySQL{}
+	case "smtp":
+		return SMTP()
+	case "ftp":
+		return FTP()
+	case "postgresql":
+		return PostgreSQL()
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package probe
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mysqlPacket(payload []byte) string {
+	header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), 0}
+	return string(header) + string(payload)
+}
+
+func TestBuiltin(t *testing.T) {
+	mysqlErr := append([]byte{0xff, 0, 0}, "Host is not allowed"...)
+	binary.LittleEndian.PutUint16(mysqlErr[1:], 1130)
+
+	for _, c := range []struct {
+		name   string
+		handle func(net.Conn)
+		ok     bool
+	}{
+		{"http", reply("HTTP/1.1 204 No Content\r\n\r\n"), true},
+		{"http", reply("HTTP/1.1 503 Service Unavailable\r\nContent-Length: 0\r\n\r\n"), false},
+		{"http", reply("SSH-2.0-OpenSSH_9.6\r\n"), false},
+		{"redis", reply("+PONG\r\n"), true},
+		{"redis", reply("-NOAUTH Authentication required.\r\n"), true},
+		{"redis", reply("-LOADING Redis is loading the dataset in memory\r\n"), false},
+		{"mysql", greet(mysqlPacket(append([]byte{0x0a}, "8.0.36\x00"...))), true},
+		{"mysql", greet(mysqlPacket(mysqlErr)), false},
+		{"smtp", greet("220 mx.example.com ESMTP\r\n"), true},
+		{"smtp", greet("554 No SMTP service here\r\n"), false},
+		{"ftp", greet("220-Welcome\r\n220 Ready\r\n"), true},
+		{"postgresql", reply("N"), true},
+		{"postgresql", reply("S"), true},
+		{"postgresql", reply("E"), false},
+	} {
+		err := NewChecker(Builtin(c.name)).CheckAddr(serve(t, c.handle), time.Second)
+		if c.ok != (err == nil) {
+			t.Fatalf("unexpected result of %s: %v", c.name, err)
+		}
+	}
+	if Builtin("gopher") != nil {
+		t.Fatal("unknown probe returned")
+	}
+}
+
+func TestHTTPRequest(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	addr := serve(t, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		requests <- req
+		_, _ = conn.Write([]byte("HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n"))
+	})
+	probe := &HTTP{Method: http.MethodHead, Path: "/healthz", MinStatus: 404, MaxStatus: 404}
+	if err := NewChecker(probe).CheckAddr(addr, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	if req.Method != http.MethodHead || req.URL.Path != "/healthz" || !strings.HasPrefix(req.Host, "127.0.0.1:") {
+		t.Fatalf("unexpected request: %s %s %s", req.Method, req.URL, req.Host)
+	}
+}
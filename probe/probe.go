@@ -0,0 +1,326 @@
+// Package probe checks the health of applications beyond the TCP handshake.
+//
+// A SYN-ACK proves only that the kernel is listening. A Checker of this
+// package completes the connection and runs a Probe on it, e.g. sending an
+// HTTP request and expecting a 2xx status. The results are reported with the
+// same types as tcpshaker.Checker.CheckAddr: nil for success,
+// *tcpshaker.ErrConnect and tcpshaker.ErrTimeout for the failures of
+// connecting, and *ErrProbe for the failures of the Probe.
+package probe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"regexp"
+	"syscall"
+	"time"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+	"github.com/tevino/tcp-shaker/proxy"
+	"github.com/tevino/tcp-shaker/proxyproto"
+)
+
+// Probe checks the application on an established connection.
+type Probe interface {
+	// Name returns the name of the Probe used in errors, e.g. "http".
+	Name() string
+	// Probe talks to the application over conn, whose deadline is set to the
+	// deadline of the check. A nil error means the application is healthy.
+	Probe(ctx context.Context, conn net.Conn) error
+}
+
+// ErrProbe indicates the connection was established but the Probe failed.
+type ErrProbe struct {
+	Probe string
+	Err   error
+}
+
+func (e *ErrProbe) Error() string {
+	return fmt.Sprintf("probe %s failed: %v", e.Probe, e.Err)
+}
+
+// Unwrap returns the underlying error, tcpshaker.ErrTimeout if the deadline
+// exceeded during probing.
+func (e *ErrProbe) Unwrap() error { return e.Err }
+
+// ErrUnexpectedResponse indicates the response does not match the expected one.
+var ErrUnexpectedResponse = errors.New("unexpected response")
+
+// defaultReadLimit is the maximum number of bytes read by Expect by default.
+const defaultReadLimit = 4096
+
+// Expect sends Send, if any, and reads the response until it matches Prefix
+// or Match, ReadLimit bytes are read or the connection is closed.
+// If neither Prefix nor Match is given, any response is accepted.
+type Expect struct {
+	ProbeName string
+	Send      []byte
+	Prefix    []byte
+	Match     *regexp.Regexp
+	// ReadLimit is the maximum number of bytes to read, 4096 if it is zero.
+	ReadLimit int
+}
+
+// Name implements Probe.
+func (e *Expect) Name() string { return e.ProbeName }
+
+// Probe implements Probe.
+func (e *Expect) Probe(ctx context.Context, conn net.Conn) error {
+	if len(e.Send) > 0 {
+		if _, err := conn.Write(e.Send); err != nil {
+			return err
+		}
+	}
+	limit := e.ReadLimit
+	if limit <= 0 {
+		limit = defaultReadLimit
+	}
+	var resp []byte
+	buf := make([]byte, min(limit, 1024))
+	for len(resp) < limit {
+		n, err := conn.Read(buf[:min(len(buf), limit-len(resp))])
+		resp = append(resp, buf[:n]...)
+		if e.matches(resp) {
+			return nil
+		}
+		if e.mismatches(resp) {
+			// No need to wait for the rest of a wrong response.
+			break
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+	}
+	return fmt.Errorf("%w %q", ErrUnexpectedResponse, truncate(resp))
+}
+
+func (e *Expect) matches(resp []byte) bool {
+	switch {
+	case e.Prefix != nil:
+		return bytes.HasPrefix(resp, e.Prefix)
+	case e.Match != nil:
+		return e.Match.Match(resp)
+	}
+	return len(resp) > 0
+}
+
+// mismatches returns whether resp can never match Prefix however more is read.
+func (e *Expect) mismatches(resp []byte) bool {
+	if e.Prefix == nil {
+		return false
+	}
+	n := min(len(resp), len(e.Prefix))
+	return !bytes.Equal(resp[:n], e.Prefix[:n])
+}
+
+// truncate shortens the response in errors.
+func truncate(resp []byte) []byte {
+	const maxLen = 64
+	if len(resp) > maxLen {
+		return resp[:maxLen]
+	}
+	return resp
+}
+
+// Option configures a Checker, see NewChecker.
+type Option func(*Checker)
+
+// ContextDialer connects to the targets, e.g. *net.Dialer and proxy.Dialer.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// WithDialer sets the dialer used to connect, e.g. to bind a local address.
+func WithDialer(dialer *net.Dialer) Option {
+	return func(c *Checker) {
+		if dialer != nil {
+			c.dialer = dialer
+		}
+	}
+}
+
+// WithProxy makes the Checker connect to the targets through given proxy,
+// e.g. &proxy.SOCKS5{Addr: "127.0.0.1:1080"}. The failures of the proxy are
+// reported as *proxy.ErrProxy, the ones of the targets as usual.
+func WithProxy(dialer proxy.Dialer) Option {
+	return func(c *Checker) {
+		if dialer != nil {
+			c.dialer = dialer
+		}
+	}
+}
+
+// WithProxyHeader sends a PROXY protocol header on every connection before
+// the Probe runs, for the backends requiring it. The Source and Destination
+// of header default to the local and remote addresses of the connection.
+func WithProxyHeader(header proxyproto.Header) Option {
+	return func(c *Checker) {
+		c.header = &header
+	}
+}
+
+// Checker connects to the target and runs a Probe on the connection.
+// It is safe for concurrent use.
+type Checker struct {
+	probe  Probe
+	dialer ContextDialer
+	header *proxyproto.Header
+}
+
+// NewChecker creates a Checker running given Probe after connecting,
+// a nil probe makes it check the handshake only.
+func NewChecker(probe Probe, opts ...Option) *Checker {
+	c := &Checker{probe: probe, dialer: &net.Dialer{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// CheckAddr performs a check with given address and timeout,
+// the timeout includes domain resolving, connecting and probing.
+func (c *Checker) CheckAddr(addr string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.CheckAddrContext(ctx, addr)
+}
+
+// CheckAddrContext is like CheckAddr but the check is bound to given ctx.
+// tcpshaker.ErrTimeout is returned if the deadline of ctx exceeded while
+// connecting, an ErrProbe wrapping it while probing.
+func (c *Checker) CheckAddrContext(ctx context.Context, addr string) error {
+	return check(ctx, c.dialer, addr, c.probe, c.header)
+}
+
+// targetKey is the context key of the target address.
+type targetKey struct{}
+
+// Target returns the address given to the check, e.g. "example.com:80".
+func Target(ctx context.Context) string {
+	addr, _ := ctx.Value(targetKey{}).(string)
+	return addr
+}
+
+// Check connects to addr with dialer and runs probe on the connection.
+// The ctx given to the probe carries addr, see Target.
+func Check(ctx context.Context, dialer ContextDialer, addr string, probe Probe) error {
+	return check(ctx, dialer, addr, probe, nil)
+}
+
+// proxyHeaderName is the name of the failed Probe in the errors of sending
+// the PROXY protocol header.
+const proxyHeaderName = "proxy-header"
+
+func check(ctx context.Context, dialer ContextDialer, addr string, probe Probe, header *proxyproto.Header) error {
+	ctx = context.WithValue(ctx, targetKey{}, addr)
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return dialError(ctx, err)
+	}
+	defer conn.Close()
+	if probe == nil && header == nil {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			name := proxyHeaderName
+			if probe != nil {
+				name = probe.Name()
+			}
+			return &ErrProbe{Probe: name, Err: err}
+		}
+	}
+	stop := context.AfterFunc(ctx, func() {
+		// Interrupt the probe once ctx is canceled.
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if header != nil {
+		if err := writeProxyHeader(conn, *header); err != nil {
+			return probeError(ctx, proxyHeaderName, err)
+		}
+	}
+	if probe == nil {
+		return nil
+	}
+	if err := probe.Probe(ctx, conn); err != nil {
+		return probeError(ctx, probe.Name(), err)
+	}
+	return nil
+}
+
+// writeProxyHeader writes header to conn, whose addresses are used by default.
+func writeProxyHeader(conn net.Conn, header proxyproto.Header) error {
+	if !header.Source.IsValid() {
+		header.Source = addrPort(conn.LocalAddr())
+	}
+	if !header.Destination.IsValid() {
+		header.Destination = addrPort(conn.RemoteAddr())
+	}
+	b, err := header.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(b)
+	return err
+}
+
+// addrPort returns the netip.AddrPort of a TCP address, a zero one otherwise.
+func addrPort(addr net.Addr) netip.AddrPort {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.AddrPort()
+	}
+	return netip.AddrPort{}
+}
+
+// probeError converts the error of probing to an ErrProbe.
+func probeError(ctx context.Context, name string, err error) error {
+	if ctx.Err() != nil || errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, tcpshaker.ErrTimeout) {
+		err = probeCtxErr(ctx)
+	} else if errors.Is(err, io.EOF) {
+		err = fmt.Errorf("%w: connection closed", ErrUnexpectedResponse)
+	}
+	return &ErrProbe{Probe: name, Err: err}
+}
+
+// dialError converts the error of dialing to the error types of tcpshaker.
+func dialError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return probeCtxErr(ctx)
+	}
+	var proxyErr *proxy.ErrProxy
+	var connectErr *tcpshaker.ErrConnect
+	if errors.As(err, &proxyErr) || errors.As(err, &connectErr) || err == tcpshaker.ErrTimeout {
+		// Already converted, e.g. by a proxy.Dialer.
+		return err
+	}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return tcpshaker.WrapConnectError(errno)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return tcpshaker.ErrTimeout
+	}
+	return err
+}
+
+// probeCtxErr is like the ctx errors of tcpshaker.Checker, the deadline of
+// conn could exceed slightly before ctx does.
+func probeCtxErr(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err()
+	}
+	return tcpshaker.ErrTimeout
+}
@@ -0,0 +1,231 @@
+package probe
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"syscall"
+	"testing"
+	"time"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+	"github.com/tevino/tcp-shaker/proxy"
+	"github.com/tevino/tcp-shaker/proxyproto"
+)
+
+// serve starts a server handling every connection with handle.
+func serve(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// reply returns a handler reading a request and writing resp.
+func reply(resp string) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		buf := make([]byte, 1024)
+		_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		_, _ = conn.Read(buf)
+		_, _ = conn.Write([]byte(resp))
+	}
+}
+
+// greet returns a handler writing the greeting upon connecting.
+func greet(greeting string) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		_, _ = conn.Write([]byte(greeting))
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestExpect(t *testing.T) {
+	addr := serve(t, reply("+PONG\r\n"))
+	for _, c := range []struct {
+		probe *Expect
+		ok    bool
+	}{
+		{&Expect{Send: []byte("PING\r\n"), Prefix: []byte("+PONG")}, true},
+		{&Expect{Send: []byte("PING\r\n"), Prefix: []byte("+PANG")}, false},
+		{&Expect{Send: []byte("PING\r\n"), Match: regexp.MustCompile(`PONG\r\n$`)}, true},
+		{&Expect{Send: []byte("PING\r\n")}, true},
+	} {
+		err := NewChecker(c.probe).CheckAddr(addr, time.Second)
+		if c.ok != (err == nil) {
+			t.Fatalf("unexpected result of %+v: %v", c.probe, err)
+		}
+		if err != nil && !errors.Is(err, ErrUnexpectedResponse) {
+			t.Fatalf("expected ErrUnexpectedResponse, got %v", err)
+		}
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	// refused
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	err = NewChecker(Redis()).CheckAddr(addr, time.Second)
+	var errConnect *tcpshaker.ErrConnect
+	if !errors.As(err, &errConnect) || !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("expected ErrConnect, got %v", err)
+	}
+
+	// no response within the timeout
+	addr = serve(t, func(conn net.Conn) { time.Sleep(time.Second) })
+	err = NewChecker(Redis()).CheckAddr(addr, 100*time.Millisecond)
+	var errProbe *ErrProbe
+	if !errors.As(err, &errProbe) || errProbe.Probe != "redis" || !errors.Is(err, tcpshaker.ErrTimeout) {
+		t.Fatalf("expected ErrProbe(ErrTimeout), got %v", err)
+	}
+
+	// canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	err = NewChecker(Redis()).CheckAddrContext(ctx, addr)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// closed without response, the request is read so that a FIN is sent instead of an RST
+	addr = serve(t, func(conn net.Conn) { _, _ = conn.Read(make([]byte, 1024)) })
+	err = NewChecker(Redis()).CheckAddr(addr, time.Second)
+	if !errors.Is(err, ErrUnexpectedResponse) {
+		t.Fatalf("expected ErrUnexpectedResponse, got %v", err)
+	}
+
+	// a wrong response on a connection kept open is reported before the deadline
+	addr = serve(t, func(conn net.Conn) {
+		_, _ = conn.Write([]byte("-ERR"))
+		time.Sleep(time.Second)
+	})
+	startedAt := time.Now()
+	err = NewChecker(&Expect{ProbeName: "banner", Prefix: []byte("+PONG")}).CheckAddr(addr, 500*time.Millisecond)
+	if !errors.Is(err, ErrUnexpectedResponse) || errors.Is(err, tcpshaker.ErrTimeout) {
+		t.Fatalf("expected ErrUnexpectedResponse, got %v", err)
+	}
+	if elapsed := time.Since(startedAt); elapsed >= 500*time.Millisecond {
+		t.Fatalf("expected the mismatch reported immediately, took %s", elapsed)
+	}
+
+	// handshake only
+	if err := NewChecker(nil).CheckAddr(addr, time.Second); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+}
+
+func TestCheckWithProxy(t *testing.T) {
+	// A stand-in of an HTTP proxy tunneling to the target of the first CONNECT.
+	target := serve(t, reply("+PONG\r\n"))
+	proxyAddr := serve(t, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		if req.Host != target {
+			_, _ = conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+			return
+		}
+		upstream, err := net.Dial("tcp", target)
+		if err != nil {
+			return
+		}
+		defer upstream.Close()
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() { _, _ = io.Copy(upstream, conn) }()
+		_, _ = io.Copy(conn, upstream)
+	})
+	c := NewChecker(Redis(), WithProxy(&proxy.HTTPConnect{Addr: proxyAddr}))
+
+	if err := c.CheckAddr(target, time.Second); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	err := c.CheckAddr("127.0.0.1:1", time.Second)
+	var errConnect *tcpshaker.ErrConnect
+	if !errors.As(err, &errConnect) || !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("expected ErrConnect, got %v", err)
+	}
+
+	// proxy down
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	err = NewChecker(Redis(), WithProxy(&proxy.SOCKS5{Addr: l.Addr().String()})).CheckAddr(target, time.Second)
+	var errProxy *proxy.ErrProxy
+	if !errors.As(err, &errProxy) || errors.As(err, &errConnect) {
+		t.Fatalf("expected ErrProxy, got %v", err)
+	}
+}
+
+func TestCheckWithProxyHeader(t *testing.T) {
+	// A backend closing the connections without a valid PROXY protocol header.
+	headers := make(chan *proxyproto.Header, 1)
+	addr := serve(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		header, err := proxyproto.Read(r)
+		if err != nil {
+			return
+		}
+		headers <- header
+		reply("+PONG\r\n")(conn)
+	})
+
+	src := netip.MustParseAddrPort("192.0.2.1:56324")
+	for _, version := range []int{1, 2} {
+		c := NewChecker(Redis(), WithProxyHeader(proxyproto.Header{Version: version, Source: src}))
+		if err := c.CheckAddr(addr, time.Second); err != nil {
+			t.Fatalf("expected success of version %d, got %v", version, err)
+		}
+		header := <-headers
+		// The destination defaults to the remote address of the connection.
+		if header.Version != version || header.Source != src || header.Destination.String() != addr {
+			t.Fatalf("unexpected header %+v", header)
+		}
+	}
+
+	// handshake only
+	if err := NewChecker(nil, WithProxyHeader(proxyproto.Header{Version: 1})).CheckAddr(addr, time.Second); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if header := <-headers; header.Source.Addr().String() != "127.0.0.1" {
+		t.Fatalf("unexpected header %+v", header)
+	}
+
+	// without the header the connection is closed
+	err := NewChecker(Redis()).CheckAddr(addr, time.Second)
+	if !errors.Is(err, ErrUnexpectedResponse) {
+		t.Fatalf("expected ErrUnexpectedResponse, got %v", err)
+	}
+
+	// an invalid header
+	err = NewChecker(nil, WithProxyHeader(proxyproto.Header{Version: 3})).CheckAddr(addr, time.Second)
+	var errProbe *ErrProbe
+	if !errors.As(err, &errProbe) || errProbe.Probe != "proxy-header" || !errors.Is(err, proxyproto.ErrInvalidHeader) {
+		t.Fatalf("expected ErrProbe(ErrInvalidHeader), got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ErrCertificateExpiring indicates a certificate of the chain expires within
+// the threshold of the TLS probe.
+var ErrCertificateExpiring = errors.New("certificate expiring")
+
+// TLS runs a TLS handshake and inspects the certificate chain of the server,
+// the handshake fails if the chain is not trusted or not valid for the server
+// name unless InsecureSkipVerify is set.
+// Next, if any, is run on the TLS connection afterwards, e.g. an HTTP probe.
+type TLS struct {
+	// ServerName is used for SNI and hostname verification, the host of the
+	// target address by default, see Target.
+	ServerName string
+	// NextProtos are the protocols offered by ALPN, e.g. "h2".
+	NextProtos []string
+	// RootCAs are the trusted roots, the ones of the system by default.
+	RootCAs *x509.CertPool
+	// InsecureSkipVerify makes the failures of verification reported only.
+	InsecureSkipVerify bool
+	// ExpiryThreshold fails the probe with ErrCertificateExpiring if any
+	// certificate of the chain expires within it, e.g. 14 * 24 * time.Hour.
+	ExpiryThreshold time.Duration
+	// Report is called with the result of every handshake if not nil.
+	Report func(*TLSReport)
+	Next   Probe
+}
+
+// TLSReport is the result of a TLS handshake.
+type TLSReport struct {
+	ServerName         string
+	HandshakeLatency   time.Duration
+	Version            uint16
+	CipherSuite        uint16
+	NegotiatedProtocol string
+	// Certificates are the certificates sent by the server, leaf first.
+	Certificates []*x509.Certificate
+	// NotAfter is the earliest expiry of Certificates.
+	NotAfter time.Time
+	// VerifyError is the error of verifying the chain against the roots, nil if trusted.
+	VerifyError error
+	// HostnameError is the error of verifying the leaf for ServerName, nil if valid.
+	HostnameError error
+}
+
+// VersionName returns the name of the negotiated version, e.g. "TLS 1.3".
+func (r *TLSReport) VersionName() string { return tls.VersionName(r.Version) }
+
+// CipherSuiteName returns the name of the negotiated cipher suite.
+func (r *TLSReport) CipherSuiteName() string { return tls.CipherSuiteName(r.CipherSuite) }
+
+// ExpiresIn returns the time left until the earliest expiry of the chain.
+func (r *TLSReport) ExpiresIn() time.Duration { return time.Until(r.NotAfter) }
+
+// Name implements Probe.
+func (t *TLS) Name() string {
+	if t.Next != nil {
+		return "tls+" + t.Next.Name()
+	}
+	return "tls"
+}
+
+// Probe implements Probe.
+func (t *TLS) Probe(ctx context.Context, conn net.Conn) error {
+	serverName := t.ServerName
+	if serverName == "" {
+		serverName, _, _ = net.SplitHostPort(Target(ctx))
+	}
+	report := &TLSReport{ServerName: serverName}
+	config := &tls.Config{
+		ServerName: serverName,
+		NextProtos: t.NextProtos,
+		// The chain is verified in VerifyConnection to report the failures.
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			t.inspect(report, cs.PeerCertificates)
+			if t.InsecureSkipVerify {
+				return nil
+			}
+			if report.VerifyError != nil {
+				return report.VerifyError
+			}
+			return report.HostnameError
+		},
+	}
+	tlsConn := tls.Client(conn, config)
+	startedAt := time.Now()
+	err := tlsConn.HandshakeContext(ctx)
+	report.HandshakeLatency = time.Since(startedAt)
+	if err == nil {
+		state := tlsConn.ConnectionState()
+		report.Version = state.Version
+		report.CipherSuite = state.CipherSuite
+		report.NegotiatedProtocol = state.NegotiatedProtocol
+	}
+	if t.Report != nil {
+		t.Report(report)
+	}
+	if err != nil {
+		return err
+	}
+
+	if t.ExpiryThreshold > 0 && report.ExpiresIn() < t.ExpiryThreshold {
+		return fmt.Errorf("%w: expires at %s", ErrCertificateExpiring, report.NotAfter.Format(time.RFC3339))
+	}
+	if t.Next != nil {
+		return t.Next.Probe(ctx, tlsConn)
+	}
+	return nil
+}
+
+// inspect verifies the chain and fills the certificates of report.
+func (t *TLS) inspect(report *TLSReport, certs []*x509.Certificate) {
+	report.Certificates = certs
+	if len(certs) == 0 {
+		report.VerifyError = errors.New("tls: no certificate from server")
+		report.HostnameError = report.VerifyError
+		return
+	}
+	report.NotAfter = certs[0].NotAfter
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+		if cert.NotAfter.Before(report.NotAfter) {
+			report.NotAfter = cert.NotAfter
+		}
+	}
+	_, report.VerifyError = certs[0].Verify(x509.VerifyOptions{
+		Roots:         t.RootCAs,
+		Intermediates: intermediates,
+	})
+	report.HostnameError = certs[0].VerifyHostname(report.ServerName)
+}
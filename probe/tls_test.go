@@ -0,0 +1,89 @@
+package probe
+
+import (
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func startTLSServer(t *testing.T) (string, *x509.CertPool) {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	return ts.Listener.Addr().String(), roots
+}
+
+func TestTLS(t *testing.T) {
+	addr, roots := startTLSServer(t)
+
+	var report *TLSReport
+	probe := &TLS{
+		ServerName: "example.com",
+		NextProtos: []string{"h2", "http/1.1"},
+		RootCAs:    roots,
+		Report:     func(r *TLSReport) { report = r },
+	}
+	if err := NewChecker(probe).CheckAddr(addr, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if report.VersionName() != "TLS 1.3" || report.CipherSuiteName() == "" || report.NegotiatedProtocol != "h2" {
+		t.Fatalf("unexpected report: %s %s %q", report.VersionName(), report.CipherSuiteName(), report.NegotiatedProtocol)
+	}
+	if len(report.Certificates) == 0 || report.NotAfter.IsZero() || report.ExpiresIn() <= 0 {
+		t.Fatalf("certificates not inspected: %+v", report)
+	}
+	if report.HandshakeLatency <= 0 || report.VerifyError != nil || report.HostnameError != nil {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	// The certificate of httptest is valid for 127.0.0.1 as well.
+	probe.ServerName = ""
+	if err := NewChecker(probe).CheckAddr(addr, time.Second); err != nil || report.ServerName != "127.0.0.1" {
+		t.Fatalf("expected success for %s, got %v", report.ServerName, err)
+	}
+
+	// HTTP over TLS
+	if err := NewChecker(&TLS{RootCAs: roots, Next: &HTTP{}}).CheckAddr(addr, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSFailures(t *testing.T) {
+	addr, roots := startTLSServer(t)
+
+	var report *TLSReport
+	onReport := func(r *TLSReport) { report = r }
+	var errProbe *ErrProbe
+
+	// untrusted
+	err := NewChecker(&TLS{Report: onReport}).CheckAddr(addr, time.Second)
+	if !errors.As(err, &errProbe) || report.VerifyError == nil {
+		t.Fatalf("expected verification failure, got %v", err)
+	}
+
+	// hostname mismatch
+	err = NewChecker(&TLS{ServerName: "wrong.test", RootCAs: roots, Report: onReport}).CheckAddr(addr, time.Second)
+	if !errors.As(err, &errProbe) || report.HostnameError == nil || report.VerifyError != nil {
+		t.Fatalf("expected hostname mismatch, got %v", err)
+	}
+
+	// reported only
+	err = NewChecker(&TLS{ServerName: "wrong.test", InsecureSkipVerify: true, Report: onReport}).CheckAddr(addr, time.Second)
+	if err != nil || report.HostnameError == nil || report.VerifyError == nil {
+		t.Fatalf("expected failures reported only, got %v", err)
+	}
+
+	// expiring
+	threshold := time.Until(report.NotAfter) + 24*time.Hour
+	err = NewChecker(&TLS{RootCAs: roots, ExpiryThreshold: threshold}).CheckAddr(addr, time.Second)
+	if !errors.Is(err, ErrCertificateExpiring) {
+		t.Fatalf("expected ErrCertificateExpiring, got %v", err)
+	}
+}
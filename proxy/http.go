@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+)
+
+// HTTPConnect connects to targets through an HTTP proxy with the CONNECT
+// method(RFC 9110), host names are resolved by the proxy.
+//
+// HTTP has no standard status for the reasons of failure, the ones used by
+// common proxies for a failed connection to the target are mapped: 502 Bad Gateway
+// to ECONNREFUSED, 504 Gateway Timeout to tcpshaker.ErrTimeout.
+// The other statuses, including 503 Service Unavailable which is also sent by an
+// overloaded or misconfigured proxy, are reported as *ErrProxy wrapping *ErrHTTPStatus.
+type HTTPConnect struct {
+	// Addr is the address of the proxy, e.g. "127.0.0.1:3128".
+	Addr string
+	// Username and Password are sent with basic authentication if Username is not empty.
+	Username string
+	Password string
+	// Header is added to the CONNECT requests if not nil.
+	Header http.Header
+	// Dialer connects to the proxy, a zero net.Dialer if nil.
+	Dialer *net.Dialer
+}
+
+// ErrHTTPStatus is the status of a failed CONNECT request.
+type ErrHTTPStatus struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *ErrHTTPStatus) Error() string {
+	return "http connect: " + e.Status
+}
+
+// httpStatusError converts the status of a failed CONNECT request.
+func httpStatusError(addr string, resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusBadGateway:
+		return tcpshaker.WrapConnectError(syscall.ECONNREFUSED)
+	case http.StatusGatewayTimeout:
+		return tcpshaker.ErrTimeout
+	}
+	return &ErrProxy{Proxy: addr, Err: &ErrHTTPStatus{StatusCode: resp.StatusCode, Status: resp.Status}}
+}
+
+// DialContext connects to addr through the proxy, network must be TCP.
+func (h *HTTPConnect) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return dial(ctx, h.Dialer, network, h.Addr, func(conn net.Conn) (net.Conn, error) {
+		return h.handshake(conn, addr)
+	})
+}
+
+func (h *HTTPConnect) handshake(conn net.Conn, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	for k, v := range h.Header {
+		req.Header[k] = v
+	}
+	if h.Username != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(h.Username + ":" + h.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	w := bufio.NewWriter(conn)
+	// The errors of writing to w are returned by Flush.
+	fmt.Fprintf(w, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+	_ = req.Header.Write(w)
+	_, _ = w.WriteString("\r\n")
+	if err := w.Flush(); err != nil {
+		return nil, handshakeError(h.Addr, err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, handshakeError(h.Addr, err)
+	}
+	// The body of a failure is left unread since the connection is closed.
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, httpStatusError(h.Addr, resp)
+	}
+	if r.Buffered() > 0 {
+		// The target spoke first, e.g. a banner of SMTP.
+		return &bufferedConn{Conn: conn, r: r}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn whose reads are served by r first.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
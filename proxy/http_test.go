@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+)
+
+// httpServer is an in-process stand-in of an HTTP proxy.
+type httpServer struct {
+	// authorization is the expected Proxy-Authorization if not empty.
+	authorization string
+	// status is sent instead of connecting to the target if not zero.
+	status int
+}
+
+func (s *httpServer) start(t *testing.T) string {
+	return serve(t, s.handle)
+}
+
+func (s *httpServer) handle(conn net.Conn) {
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil || req.Method != http.MethodConnect {
+		return
+	}
+	status := s.status
+	if s.authorization != "" && req.Header.Get("Proxy-Authorization") != s.authorization {
+		status = http.StatusProxyAuthRequired
+	}
+	var target net.Conn
+	if status == 0 {
+		status = http.StatusOK
+		if target, err = net.Dial("tcp", req.Host); err != nil {
+			status = http.StatusBadGateway
+		}
+	}
+	fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\nContent-Length: 0\r\n\r\n", status, http.StatusText(status))
+	if target != nil {
+		tunnel(conn, target)
+	}
+}
+
+func TestHTTPConnect(t *testing.T) {
+	addr := (&httpServer{}).start(t)
+
+	conn, err := dialTimeout(&HTTPConnect{Addr: addr}, greeter(t))
+	expectGreeting(t, conn, err)
+	_, err = dialTimeout(&HTTPConnect{Addr: addr}, closedAddr(t))
+	expectConnectErr(t, err, syscall.ECONNREFUSED)
+}
+
+func TestHTTPConnectAuth(t *testing.T) {
+	// base64("user:secret")
+	addr := (&httpServer{authorization: "Basic dXNlcjpzZWNyZXQ="}).start(t)
+
+	conn, err := dialTimeout(&HTTPConnect{Addr: addr, Username: "user", Password: "secret"}, greeter(t))
+	expectGreeting(t, conn, err)
+	_, err = dialTimeout(&HTTPConnect{Addr: addr}, greeter(t))
+	expectProxyErr(t, err)
+	var statusErr *ErrHTTPStatus
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusProxyAuthRequired {
+		t.Fatalf("expected ErrHTTPStatus(407), got %v", err)
+	}
+}
+
+func TestHTTPConnectStatuses(t *testing.T) {
+	addr := (&httpServer{status: http.StatusBadGateway}).start(t)
+	_, err := dialTimeout(&HTTPConnect{Addr: addr}, "127.0.0.1:80")
+	expectConnectErr(t, err, syscall.ECONNREFUSED)
+
+	// The proxy itself may be unavailable.
+	addr = (&httpServer{status: http.StatusServiceUnavailable}).start(t)
+	_, err = dialTimeout(&HTTPConnect{Addr: addr}, "127.0.0.1:80")
+	expectProxyErr(t, err)
+	var statusErr *ErrHTTPStatus
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected ErrHTTPStatus(503), got %v", err)
+	}
+
+	addr = (&httpServer{status: http.StatusGatewayTimeout}).start(t)
+	_, err = dialTimeout(&HTTPConnect{Addr: addr}, "127.0.0.1:80")
+	if err != tcpshaker.ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	addr = (&httpServer{status: http.StatusForbidden}).start(t)
+	_, err = dialTimeout(&HTTPConnect{Addr: addr}, "127.0.0.1:80")
+	expectProxyErr(t, err)
+}
@@ -0,0 +1,108 @@
+// Package proxy connects to targets through SOCKS5 and HTTP CONNECT proxies
+// for the checks of package probe, see probe.WithProxy.
+//
+// The replies of proxies are mapped onto the error types of direct checks:
+// a target refusing the connection is reported as *tcpshaker.ErrConnect
+// wrapping ECONNREFUSED, an unreachable one as *tcpshaker.ErrConnect wrapping
+// EHOSTUNREACH or ENETUNREACH, and so on. The failures of the proxy itself,
+// e.g. it is down or rejects the credentials, are reported as *ErrProxy so
+// they are never mistaken for the failures of the target.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// ErrProxy indicates the check failed at the proxy rather than at the target.
+type ErrProxy struct {
+	// Proxy is the address of the proxy.
+	Proxy string
+	Err   error
+}
+
+func (e *ErrProxy) Error() string {
+	return fmt.Sprintf("proxy %s: %v", e.Proxy, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ErrProxy) Unwrap() error { return e.Err }
+
+// Dialer connects to addr through a proxy.
+type Dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// FromURL returns the Dialer of the proxy at u, which is either
+// "socks5://[user:password@]host:port" or "http://[user:password@]host:port".
+func FromURL(u string) (Dialer, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL '%s': missing host", u)
+	}
+	var username, password string
+	if parsed.User != nil {
+		username = parsed.User.Username()
+		password, _ = parsed.User.Password()
+	}
+	switch parsed.Scheme {
+	case "socks5", "socks5h":
+		return &SOCKS5{Addr: parsed.Host, Username: username, Password: password}, nil
+	case "http":
+		return &HTTPConnect{Addr: parsed.Host, Username: username, Password: password}, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme '%s'", parsed.Scheme)
+}
+
+// dial connects to the proxy at addr and runs handshake on the connection
+// with its deadline bound to ctx. The connection is closed on failure.
+func dial(ctx context.Context, dialer *net.Dialer, network, addr string, handshake func(conn net.Conn) (net.Conn, error)) (net.Conn, error) {
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &ErrProxy{Proxy: addr, Err: err}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		// Interrupt the handshake once ctx is canceled.
+		_ = conn.SetDeadline(time.Now())
+	})
+	tunnel, err := handshake(conn)
+	if !stop() || err != nil {
+		conn.Close()
+		if err == nil {
+			err = ctx.Err()
+		}
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return tunnel, nil
+}
+
+// handshakeError converts the errors of talking to the proxy,
+// an EOF means the proxy closed the connection unexpectedly.
+func handshakeError(addr string, err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		// Most likely the proxy is still waiting for the target.
+		return err
+	}
+	return &ErrProxy{Proxy: addr, Err: err}
+}
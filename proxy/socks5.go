@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"syscall"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+)
+
+// SOCKS5 connects to targets through a SOCKS5 proxy(RFC 1928), host names
+// are resolved by the proxy.
+type SOCKS5 struct {
+	// Addr is the address of the proxy, e.g. "127.0.0.1:1080".
+	Addr string
+	// Username and Password are sent if Username is not empty(RFC 1929).
+	Username string
+	Password string
+	// Dialer connects to the proxy, a zero net.Dialer if nil.
+	Dialer *net.Dialer
+}
+
+// The reply codes of SOCKS5.
+const (
+	socksSucceeded           = 0x00
+	socksGeneralFailure      = 0x01
+	socksNotAllowed          = 0x02
+	socksNetworkUnreachable  = 0x03
+	socksHostUnreachable     = 0x04
+	socksConnectionRefused   = 0x05
+	socksTTLExpired          = 0x06
+	socksCommandNotSupported = 0x07
+	socksAddressNotSupported = 0x08
+)
+
+var socksReplies = map[byte]string{
+	socksGeneralFailure:      "general SOCKS server failure",
+	socksNotAllowed:          "connection not allowed by ruleset",
+	socksNetworkUnreachable:  "network unreachable",
+	socksHostUnreachable:     "host unreachable",
+	socksConnectionRefused:   "connection refused",
+	socksTTLExpired:          "TTL expired",
+	socksCommandNotSupported: "command not supported",
+	socksAddressNotSupported: "address type not supported",
+}
+
+// ErrSOCKSReply is the failure reported by a SOCKS5 proxy which could not be
+// mapped onto the errors of direct checks.
+type ErrSOCKSReply struct {
+	Code byte
+}
+
+func (e *ErrSOCKSReply) Error() string {
+	if msg, ok := socksReplies[e.Code]; ok {
+		return "socks5: " + msg
+	}
+	return fmt.Sprintf("socks5: unknown reply %#02x", e.Code)
+}
+
+// socksReplyError converts a reply code to the error of direct checks,
+// TTL expired is reported as EHOSTUNREACH just like an ICMP time exceeded
+// received by a direct check on Linux.
+func socksReplyError(code byte) error {
+	switch code {
+	case socksNetworkUnreachable:
+		return tcpshaker.WrapConnectError(syscall.ENETUNREACH)
+	case socksHostUnreachable, socksTTLExpired:
+		return tcpshaker.WrapConnectError(syscall.EHOSTUNREACH)
+	case socksConnectionRefused:
+		return tcpshaker.WrapConnectError(syscall.ECONNREFUSED)
+	}
+	return &ErrSOCKSReply{Code: code}
+}
+
+// DialContext connects to addr through the proxy, network must be TCP.
+func (s *SOCKS5) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	req, err := socksConnectRequest(addr)
+	if err != nil {
+		return nil, err
+	}
+	return dial(ctx, s.Dialer, network, s.Addr, func(conn net.Conn) (net.Conn, error) {
+		return conn, s.handshake(conn, req)
+	})
+}
+
+func (s *SOCKS5) handshake(conn net.Conn, req []byte) error {
+	method := byte(0x00) // no authentication
+	if s.Username != "" {
+		method = 0x02 // username/password
+	}
+	if _, err := conn.Write([]byte{0x05, 0x01, method}); err != nil {
+		return handshakeError(s.Addr, err)
+	}
+	var resp [2]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return handshakeError(s.Addr, err)
+	}
+	if resp[0] != 0x05 || resp[1] != method {
+		return &ErrProxy{Proxy: s.Addr, Err: errors.New("socks5: no acceptable authentication method")}
+	}
+	if method == 0x02 {
+		if err := s.authenticate(conn); err != nil {
+			return err
+		}
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		return handshakeError(s.Addr, err)
+	}
+	// VER REP RSV ATYP, followed by the bound address and port.
+	var reply [4]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return handshakeError(s.Addr, err)
+	}
+	if reply[0] != 0x05 {
+		return &ErrProxy{Proxy: s.Addr, Err: fmt.Errorf("socks5: unexpected version %d", reply[0])}
+	}
+	if reply[1] != socksSucceeded {
+		err := socksReplyError(reply[1])
+		if _, ok := err.(*ErrSOCKSReply); ok {
+			return &ErrProxy{Proxy: s.Addr, Err: err}
+		}
+		return err
+	}
+	var skip int
+	switch reply[3] {
+	case 0x01:
+		skip = net.IPv4len
+	case 0x04:
+		skip = net.IPv6len
+	case 0x03:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return handshakeError(s.Addr, err)
+		}
+		skip = int(n[0])
+	default:
+		return &ErrProxy{Proxy: s.Addr, Err: fmt.Errorf("socks5: unexpected address type %d", reply[3])}
+	}
+	if _, err := io.CopyN(io.Discard, conn, int64(skip+2)); err != nil {
+		return handshakeError(s.Addr, err)
+	}
+	return nil
+}
+
+func (s *SOCKS5) authenticate(conn net.Conn) error {
+	if len(s.Username) > 255 || len(s.Password) > 255 {
+		return &ErrProxy{Proxy: s.Addr, Err: errors.New("socks5: username or password too long")}
+	}
+	req := []byte{0x01, byte(len(s.Username))}
+	req = append(req, s.Username...)
+	req = append(req, byte(len(s.Password)))
+	req = append(req, s.Password...)
+	if _, err := conn.Write(req); err != nil {
+		return handshakeError(s.Addr, err)
+	}
+	var resp [2]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return handshakeError(s.Addr, err)
+	}
+	if resp[1] != 0x00 {
+		return &ErrProxy{Proxy: s.Addr, Err: errors.New("socks5: authentication failed")}
+	}
+	return nil
+}
+
+// socksConnectRequest returns the CONNECT request of addr.
+func socksConnectRequest(addr string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port '%s'", portStr)
+	}
+	req := []byte{0x05, 0x01, 0x00}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if ip.Unmap().Is4() {
+			ip4 := ip.Unmap().As4()
+			req = append(append(req, 0x01), ip4[:]...)
+		} else {
+			ip16 := ip.As16()
+			req = append(append(req, 0x04), ip16[:]...)
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("host name '%s' too long", host)
+		}
+		req = append(append(req, 0x03, byte(len(host))), host...)
+	}
+	return binary.BigEndian.AppendUint16(req, uint16(port)), nil
+}
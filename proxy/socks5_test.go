@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// socksServer is an in-process stand-in of a SOCKS5 proxy.
+type socksServer struct {
+	username, password string
+	// reply is sent instead of connecting to the target if not zero.
+	reply byte
+}
+
+func (s *socksServer) start(t *testing.T) string {
+	return serve(t, s.handle)
+}
+
+func (s *socksServer) handle(conn net.Conn) {
+	var greeting [2]byte
+	if _, err := io.ReadFull(conn, greeting[:]); err != nil {
+		return
+	}
+	methods := make([]byte, greeting[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	method := byte(0x00)
+	if s.username != "" {
+		method = 0x02
+	}
+	_, _ = conn.Write([]byte{0x05, method})
+	if method == 0x02 && !s.authenticate(conn) {
+		return
+	}
+
+	var req [4]byte
+	if _, err := io.ReadFull(conn, req[:]); err != nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 0x01, 0x04:
+		ip := make(net.IP, map[byte]int{0x01: 4, 0x04: 16}[req[3]])
+		_, _ = io.ReadFull(conn, ip)
+		host = ip.String()
+	case 0x03:
+		var n [1]byte
+		_, _ = io.ReadFull(conn, n[:])
+		name := make([]byte, n[0])
+		_, _ = io.ReadFull(conn, name)
+		host = string(name)
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(conn, port[:]); err != nil {
+		return
+	}
+
+	reply := s.reply
+	var target net.Conn
+	if reply == 0 {
+		var err error
+		target, err = net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))))
+		switch {
+		case errors.Is(err, syscall.ECONNREFUSED):
+			reply = socksConnectionRefused
+		case err != nil:
+			reply = socksGeneralFailure
+		}
+	}
+	// The bound address is a domain name to test skipping it.
+	_, _ = conn.Write([]byte{0x05, reply, 0x00, 0x03, 0x04, 'b', 'o', 'u', 'n', 0x00, 0x00})
+	if target != nil {
+		tunnel(conn, target)
+	}
+}
+
+func (s *socksServer) authenticate(conn net.Conn) bool {
+	var b [2]byte
+	_, _ = io.ReadFull(conn, b[:])
+	username := make([]byte, b[1])
+	_, _ = io.ReadFull(conn, username)
+	_, _ = io.ReadFull(conn, b[:1])
+	password := make([]byte, b[0])
+	_, _ = io.ReadFull(conn, password)
+	if string(username) != s.username || string(password) != s.password {
+		_, _ = conn.Write([]byte{0x01, 0x01})
+		return false
+	}
+	_, _ = conn.Write([]byte{0x01, 0x00})
+	return true
+}
+
+func TestSOCKS5(t *testing.T) {
+	addr := (&socksServer{}).start(t)
+
+	conn, err := dialTimeout(&SOCKS5{Addr: addr}, greeter(t))
+	expectGreeting(t, conn, err)
+	_, err = dialTimeout(&SOCKS5{Addr: addr}, closedAddr(t))
+	expectConnectErr(t, err, syscall.ECONNREFUSED)
+	// The host name is resolved by the proxy.
+	_, port, _ := net.SplitHostPort(greeter(t))
+	conn, err = dialTimeout(&SOCKS5{Addr: addr}, net.JoinHostPort("localhost", port))
+	expectGreeting(t, conn, err)
+}
+
+func TestSOCKS5Auth(t *testing.T) {
+	addr := (&socksServer{username: "user", password: "secret"}).start(t)
+
+	conn, err := dialTimeout(&SOCKS5{Addr: addr, Username: "user", Password: "secret"}, greeter(t))
+	expectGreeting(t, conn, err)
+	_, err = dialTimeout(&SOCKS5{Addr: addr, Username: "user", Password: "wrong"}, greeter(t))
+	expectProxyErr(t, err)
+	// No acceptable method.
+	_, err = dialTimeout(&SOCKS5{Addr: addr}, greeter(t))
+	expectProxyErr(t, err)
+}
+
+func TestSOCKS5Replies(t *testing.T) {
+	for code, errno := range map[byte]syscall.Errno{
+		socksNetworkUnreachable: syscall.ENETUNREACH,
+		socksHostUnreachable:    syscall.EHOSTUNREACH,
+		socksConnectionRefused:  syscall.ECONNREFUSED,
+		socksTTLExpired:         syscall.EHOSTUNREACH,
+	} {
+		addr := (&socksServer{reply: code}).start(t)
+		_, err := dialTimeout(&SOCKS5{Addr: addr}, "127.0.0.1:80")
+		expectConnectErr(t, err, errno)
+	}
+
+	for _, code := range []byte{socksGeneralFailure, socksNotAllowed, socksCommandNotSupported, 0x42} {
+		addr := (&socksServer{reply: code}).start(t)
+		_, err := dialTimeout(&SOCKS5{Addr: addr}, "127.0.0.1:80")
+		expectProxyErr(t, err)
+		var replyErr *ErrSOCKSReply
+		if !errors.As(err, &replyErr) || replyErr.Code != code {
+			t.Fatalf("expected ErrSOCKSReply(%#02x), got %v", code, err)
+		}
+	}
+}
+
+func TestSOCKSConnectRequest(t *testing.T) {
+	for addr, expected := range map[string][]byte{
+		"1.2.3.4:80":          {0x05, 0x01, 0x00, 0x01, 1, 2, 3, 4, 0, 80},
+		"[::1]:443":           {0x05, 0x01, 0x00, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0x01, 0xbb},
+		"example.com:22":      {0x05, 0x01, 0x00, 0x03, 11, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm', 0, 22},
+		"[::ffff:1.2.3.4]:80": {0x05, 0x01, 0x00, 0x01, 1, 2, 3, 4, 0, 80},
+	} {
+		req, err := socksConnectRequest(addr)
+		if err != nil || string(req) != string(expected) {
+			t.Errorf("socksConnectRequest(%q) = %v, %v; expected %v", addr, req, err, expected)
+		}
+	}
+	for _, addr := range []string{"1.2.3.4", "1.2.3.4:http"} {
+		if _, err := socksConnectRequest(addr); err == nil {
+			t.Errorf("expected error of %q", addr)
+		}
+	}
+}
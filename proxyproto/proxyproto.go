@@ -0,0 +1,180 @@
+// Package proxyproto formats and parses the headers of the PROXY protocol
+// version 1 and 2, which are required on every connection by the backends
+// behind some L4 load balancers, see probe.WithProxyHeader.
+//
+// The specification: https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// signature is the signature of the binary header of version 2.
+var signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ErrInvalidHeader indicates a malformed header.
+var ErrInvalidHeader = errors.New("invalid PROXY protocol header")
+
+// Header is a PROXY protocol header of a TCP connection.
+type Header struct {
+	// Version is 1 for the text format or 2 for the binary one.
+	Version int
+	// Source and Destination are the addresses of the proxied connection,
+	// they must be both IPv4 or both IPv6. If neither is valid, the header
+	// tells the connection is not proxied, i.e. "UNKNOWN" of version 1 and
+	// the LOCAL command of version 2.
+	Source      netip.AddrPort
+	Destination netip.AddrPort
+}
+
+// local returns whether the connection is not proxied.
+func (h *Header) local() bool {
+	return !h.Source.IsValid() && !h.Destination.IsValid()
+}
+
+// Marshal returns the header in the format of its version.
+func (h *Header) Marshal() ([]byte, error) {
+	src := netip.AddrPortFrom(h.Source.Addr().Unmap(), h.Source.Port())
+	dst := netip.AddrPortFrom(h.Destination.Addr().Unmap(), h.Destination.Port())
+	if !h.local() && (!src.IsValid() || !dst.IsValid() || src.Addr().Is4() != dst.Addr().Is4()) {
+		return nil, fmt.Errorf("%w: source %s and destination %s must be valid and of the same family", ErrInvalidHeader, h.Source, h.Destination)
+	}
+	switch h.Version {
+	case 1:
+		return h.marshalV1(src, dst), nil
+	case 2:
+		return h.marshalV2(src, dst), nil
+	}
+	return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidHeader, h.Version)
+}
+
+func (h *Header) marshalV1(src, dst netip.AddrPort) []byte {
+	if h.local() {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+	proto := "TCP4"
+	if src.Addr().Is6() {
+		proto = "TCP6"
+	}
+	return fmt.Appendf(nil, "PROXY %s %s %s %d %d\r\n", proto, src.Addr(), dst.Addr(), src.Port(), dst.Port())
+}
+
+func (h *Header) marshalV2(src, dst netip.AddrPort) []byte {
+	b := append([]byte(nil), signature...)
+	if h.local() {
+		// LOCAL, UNSPEC, no addresses
+		return append(b, 0x20, 0x00, 0x00, 0x00)
+	}
+	// PROXY
+	b = append(b, 0x21)
+	if src.Addr().Is4() {
+		// TCP over IPv4
+		b = append(b, 0x11)
+		b = binary.BigEndian.AppendUint16(b, 12)
+	} else {
+		// TCP over IPv6
+		b = append(b, 0x21)
+		b = binary.BigEndian.AppendUint16(b, 36)
+	}
+	b = append(b, src.Addr().AsSlice()...)
+	b = append(b, dst.Addr().AsSlice()...)
+	b = binary.BigEndian.AppendUint16(b, src.Port())
+	return binary.BigEndian.AppendUint16(b, dst.Port())
+}
+
+// Read reads a header of either version from r, the TLVs of version 2 are skipped.
+func Read(r *bufio.Reader) (*Header, error) {
+	prefix, err := r.Peek(len(signature))
+	if err != nil && !bytes.HasPrefix(prefix, []byte("PROXY ")) {
+		return nil, err
+	}
+	if bytes.Equal(prefix, signature) {
+		return readV2(r)
+	}
+	return readV1(r)
+}
+
+func readV1(r *bufio.Reader) (*Header, error) {
+	// The maximum length of a header of version 1 is 107 bytes.
+	line, err := r.ReadSlice('\n')
+	if err != nil || len(line) > 107 || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+	fields := strings.Fields(string(line))
+	h := &Header{Version: 1}
+	if len(fields) >= 2 && fields[0] == "PROXY" && fields[1] == "UNKNOWN" {
+		return h, nil
+	}
+	if len(fields) != 6 || fields[0] != "PROXY" || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+	src, srcErr := parseAddrPort(fields[2], fields[4])
+	dst, dstErr := parseAddrPort(fields[3], fields[5])
+	if srcErr != nil || dstErr != nil || src.Addr().Is4() != (fields[1] == "TCP4") || dst.Addr().Is4() != src.Addr().Is4() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+	h.Source, h.Destination = src, dst
+	return h, nil
+}
+
+func parseAddrPort(addr, port string) (netip.AddrPort, error) {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	return netip.AddrPortFrom(ip, uint16(p)), nil
+}
+
+func readV2(r *bufio.Reader) (*Header, error) {
+	var fixed [16]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return nil, err
+	}
+	verCmd, family := fixed[12], fixed[13]
+	body := make([]byte, binary.BigEndian.Uint16(fixed[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	if verCmd>>4 != 2 {
+		return nil, fmt.Errorf("%w: version %d", ErrInvalidHeader, verCmd>>4)
+	}
+	h := &Header{Version: 2}
+	switch verCmd & 0x0f {
+	case 0x00:
+		// LOCAL, the addresses are ignored.
+		return h, nil
+	case 0x01:
+	default:
+		return nil, fmt.Errorf("%w: command %d", ErrInvalidHeader, verCmd&0x0f)
+	}
+	var addrLen int
+	switch family {
+	case 0x11:
+		addrLen = 4
+	case 0x21:
+		addrLen = 16
+	default:
+		return nil, fmt.Errorf("%w: unsupported family %#02x", ErrInvalidHeader, family)
+	}
+	if len(body) < 2*addrLen+4 {
+		return nil, fmt.Errorf("%w: truncated addresses", ErrInvalidHeader)
+	}
+	src, _ := netip.AddrFromSlice(body[:addrLen])
+	dst, _ := netip.AddrFromSlice(body[addrLen : 2*addrLen])
+	ports := body[2*addrLen:]
+	h.Source = netip.AddrPortFrom(src, binary.BigEndian.Uint16(ports))
+	h.Destination = netip.AddrPortFrom(dst, binary.BigEndian.Uint16(ports[2:]))
+	return h, nil
+}
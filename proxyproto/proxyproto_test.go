@@ -0,0 +1,91 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	v4 := Header{Source: netip.MustParseAddrPort("192.0.2.1:56324"), Destination: netip.MustParseAddrPort("198.51.100.2:443")}
+	v6 := Header{Source: netip.MustParseAddrPort("[2001:db8::1]:56324"), Destination: netip.MustParseAddrPort("[2001:db8::2]:443")}
+	for _, c := range []struct {
+		header   Header
+		expected string
+	}{
+		{Header{Version: 1, Source: v4.Source, Destination: v4.Destination}, "PROXY TCP4 192.0.2.1 198.51.100.2 56324 443\r\n"},
+		{Header{Version: 1, Source: v6.Source, Destination: v6.Destination}, "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"},
+		{Header{Version: 1}, "PROXY UNKNOWN\r\n"},
+		{
+			Header{Version: 2, Source: v4.Source, Destination: v4.Destination},
+			"\r\n\r\n\x00\r\nQUIT\n\x21\x11\x00\x0c\xc0\x00\x02\x01\xc6\x33\x64\x02\xdc\x04\x01\xbb",
+		},
+		{
+			Header{Version: 2, Source: v6.Source, Destination: v6.Destination},
+			"\r\n\r\n\x00\r\nQUIT\n\x21\x21\x00\x24" +
+				"\x20\x01\x0d\xb8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01" +
+				"\x20\x01\x0d\xb8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\xdc\x04\x01\xbb",
+		},
+		{Header{Version: 2}, "\r\n\r\n\x00\r\nQUIT\n\x20\x00\x00\x00"},
+		// IPv4-mapped addresses are unmapped.
+		{
+			Header{Version: 1, Source: netip.MustParseAddrPort("[::ffff:192.0.2.1]:1"), Destination: netip.MustParseAddrPort("198.51.100.2:2")},
+			"PROXY TCP4 192.0.2.1 198.51.100.2 1 2\r\n",
+		},
+	} {
+		b, err := c.header.Marshal()
+		if err != nil || string(b) != c.expected {
+			t.Errorf("Marshal(%+v) = %q, %v; expected %q", c.header, b, err, c.expected)
+		}
+	}
+
+	for _, h := range []Header{
+		{Version: 3, Source: v4.Source, Destination: v4.Destination},
+		{Version: 1, Source: v4.Source, Destination: v6.Destination},
+		{Version: 2, Source: v4.Source},
+	} {
+		if _, err := h.Marshal(); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("expected ErrInvalidHeader of %+v, got %v", h, err)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	for _, h := range []Header{
+		{Version: 1, Source: netip.MustParseAddrPort("192.0.2.1:56324"), Destination: netip.MustParseAddrPort("198.51.100.2:443")},
+		{Version: 1, Source: netip.MustParseAddrPort("[2001:db8::1]:1"), Destination: netip.MustParseAddrPort("[2001:db8::2]:2")},
+		{Version: 1},
+		{Version: 2, Source: netip.MustParseAddrPort("192.0.2.1:56324"), Destination: netip.MustParseAddrPort("198.51.100.2:443")},
+		{Version: 2, Source: netip.MustParseAddrPort("[2001:db8::1]:1"), Destination: netip.MustParseAddrPort("[2001:db8::2]:2")},
+		{Version: 2},
+	} {
+		b, err := h.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The payload following the header is left unread.
+		r := bufio.NewReader(bytes.NewReader(append(b, "GET /"...)))
+		parsed, err := Read(r)
+		if err != nil || *parsed != h {
+			t.Errorf("Read(%q) = %+v, %v; expected %+v", b, parsed, err, h)
+		}
+		if rest, _ := r.ReadString(0); rest != "GET /" {
+			t.Errorf("expected the payload left, got %q", rest)
+		}
+	}
+
+	for _, b := range []string{
+		"GET / HTTP/1.1\r\n",
+		"PROXY TCP4 192.0.2.1 2001:db8::2 1 2\r\n",
+		"PROXY TCP4 192.0.2.1 198.51.100.2 1 65536\r\n",
+		"PROXY TCP4 192.0.2.1 198.51.100.2 1 2\n",
+		"\r\n\r\n\x00\r\nQUIT\n\x11\x11\x00\x00",
+		"\r\n\r\n\x00\r\nQUIT\n\x21\x11\x00\x04\xc0\x00\x02\x01",
+	} {
+		if _, err := Read(bufio.NewReader(bytes.NewReader([]byte(b)))); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("expected ErrInvalidHeader of %q, got %v", b, err)
+		}
+	}
+}
@@ -33,3 +33,17 @@ func (r *resultPipesMU) RegisterResultPipe(fd int, pipe chan error) {
 	r.fdResultPipes[fd] = pipe
 	r.l.Unlock()
 }
+
+func (r *resultPipesMU) Range(f func(int, chan error) bool) {
+	r.l.Lock()
+	pipes := make(map[int]chan error, len(r.fdResultPipes))
+	for fd, pipe := range r.fdResultPipes {
+		pipes[fd] = pipe
+	}
+	r.l.Unlock()
+	for fd, pipe := range pipes {
+		if !f(fd, pipe) {
+			return
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Package scan sweeps CIDR blocks and port ranges with the half-open
+// handshakes of tcp-shaker, it is meant for auditing your own networks.
+//
+// Every target is reported as open(SYN-ACK received), closed(RST received)
+// or filtered(no response or ICMP unreachable).
+package scan
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/netip"
+	"sync"
+	"syscall"
+	"time"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+	"github.com/tevino/tcp-shaker/throttle"
+)
+
+// State is the state of a port.
+type State int
+
+// Available states.
+const (
+	// StateOpen indicates the handshake succeeded.
+	StateOpen State = iota
+	// StateClosed indicates the connection was refused.
+	StateClosed
+	// StateFiltered indicates the check timed out or the destination was unreachable.
+	StateFiltered
+	// StateError indicates the check failed due to other errors, e.g. a local one.
+	StateError
+	// StateOpenFiltered indicates nothing was received from a UDP port,
+	// see tcpshaker.ErrOpenFiltered.
+	StateOpenFiltered
+)
+
+var stateNames = [...]string{"open", "closed", "filtered", "error", "open|filtered"}
+
+func (s State) String() string {
+	if s >= 0 && int(s) < len(stateNames) {
+		return stateNames[s]
+	}
+	return "unknown"
+}
+
+// Classify returns the State indicated by the result of a check.
+// A port answering only retransmitted SYNs is open, see tcpshaker.ErrSYNDropped.
+func Classify(err error) State {
+	var synDropped *tcpshaker.ErrSYNDropped
+	switch {
+	case err == nil, errors.As(err, &synDropped):
+		return StateOpen
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return StateClosed
+	case err == tcpshaker.ErrOpenFiltered:
+		return StateOpenFiltered
+	case errors.Is(err, tcpshaker.ErrTimeout),
+		errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.ENETUNREACH):
+		return StateFiltered
+	}
+	return StateError
+}
+
+// Result is the result of scanning a target.
+type Result struct {
+	Target  netip.AddrPort
+	State   State
+	Err     error
+	Latency time.Duration
+}
+
+// Option configures a Scanner.
+type Option func(*Scanner)
+
+// WithRate limits the number of checks started per second, 0 means unlimited.
+func WithRate(rate float64) Option {
+	return func(s *Scanner) { s.rate = rate }
+}
+
+// WithConcurrency sets the maximum number of checks in flight, 100 by default.
+func WithConcurrency(n int) Option {
+	return func(s *Scanner) {
+		if n > 0 {
+			s.concurrency = n
+		}
+	}
+}
+
+// WithTimeout sets the timeout of each check, 1s by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Scanner) {
+		if timeout > 0 {
+			s.timeout = timeout
+		}
+	}
+}
+
+// WithSeed sets the seed of the random order of targets, a random one is used by default.
+func WithSeed(seed int64) Option {
+	return func(s *Scanner) { s.seed = seed }
+}
+
+// WithLimiter makes every check wait for the permission of limiter,
+// e.g. to cap the rate and concurrency per host and subnet.
+// The timeout does not include the time spent waiting.
+func WithLimiter(limiter *throttle.Limiter) Option {
+	return func(s *Scanner) { s.limiter = limiter }
+}
+
+// Scanner checks targets in a random order.
+type Scanner struct {
+	checker     *tcpshaker.Checker
+	limiter     *throttle.Limiter
+	rate        float64
+	concurrency int
+	timeout     time.Duration
+	seed        int64
+}
+
+// NewScanner creates a Scanner performing checks with given checker,
+// whose CheckingLoop must be running.
+func NewScanner(checker *tcpshaker.Checker, opts ...Option) *Scanner {
+	s := &Scanner{
+		checker:     checker,
+		concurrency: 100,
+		timeout:     time.Second,
+		seed:        time.Now().UnixNano(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Scan checks all the targets in a random order and calls report with the
+// result of each of them, the calls are serialized.
+// It returns when all the targets are checked or ctx is done, ctx.Err() is
+// returned in the latter case.
+func (s *Scanner) Scan(ctx context.Context, targets *Targets, report func(Result)) error {
+	queue := make(chan netip.AddrPort)
+	var reportLock sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < s.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range queue {
+				r := s.check(ctx, target)
+				if r.State == StateError && ctx.Err() != nil {
+					// interrupted
+					continue
+				}
+				reportLock.Lock()
+				report(r)
+				reportLock.Unlock()
+			}
+		}()
+	}
+
+	err := s.produce(ctx, targets, queue)
+	close(queue)
+	wg.Wait()
+	return err
+}
+
+// produce feeds the targets into queue in a random order at the configured rate.
+func (s *Scanner) produce(ctx context.Context, targets *Targets, queue chan<- netip.AddrPort) error {
+	perm := newPermutation(targets.Len(), rand.New(rand.NewSource(s.seed)))
+	var interval time.Duration
+	if s.rate > 0 {
+		interval = time.Duration(float64(time.Second) / s.rate)
+	}
+	startedAt := time.Now()
+	for n := 0; ; n++ {
+		i, ok := perm.next()
+		if !ok {
+			return nil
+		}
+		if interval > 0 {
+			if wait := time.Until(startedAt.Add(time.Duration(n) * interval)); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				}
+			}
+		}
+		select {
+		case queue <- targets.At(i):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Scanner) check(ctx context.Context, target netip.AddrPort) Result {
+	if s.limiter != nil {
+		release, err := s.limiter.Acquire(ctx, target)
+		if err != nil {
+			return Result{Target: target, State: StateError, Err: err}
+		}
+		defer release()
+	}
+	startedAt := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	err := s.checker.CheckAddrContext(ctx, target.String())
+	return Result{
+		Target:  target,
+		State:   Classify(err),
+		Err:     err,
+		Latency: time.Since(startedAt),
+	}
+}
@@ -0,0 +1,92 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+	"testing"
+	"time"
+
+	tcpshaker "github.com/tevino/tcp-shaker"
+	"github.com/tevino/tcp-shaker/throttle"
+)
+
+func TestClassify(t *testing.T) {
+	cases := map[State]error{
+		StateOpen:         nil,
+		StateClosed:       connectError(syscall.ECONNREFUSED),
+		StateFiltered:     tcpshaker.ErrTimeout,
+		StateError:        errors.New("other"),
+		StateOpenFiltered: tcpshaker.ErrOpenFiltered,
+	}
+	for state, err := range cases {
+		if got := Classify(err); got != state {
+			t.Fatalf("expected %s for %v, got %s", state, err, got)
+		}
+	}
+	if Classify(connectError(syscall.EHOSTUNREACH)) != StateFiltered {
+		t.Fatal("unreachable host should be filtered")
+	}
+	if Classify(&tcpshaker.ErrSYNDropped{Retransmits: 1}) != StateOpen {
+		t.Fatal("overloaded listener should be open")
+	}
+}
+
+func connectError(errno syscall.Errno) error {
+	return fmt.Errorf("connect: %w", errno)
+}
+
+func TestScan(t *testing.T) {
+	checker := tcpshaker.NewChecker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = checker.CheckingLoop(ctx)
+	}()
+	<-checker.WaitReady()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	openPort := uint16(l.Addr().(*net.TCPAddr).Port)
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedPort := uint16(closed.Addr().(*net.TCPAddr).Port)
+	_ = closed.Close()
+
+	prefixes, _ := ParsePrefixes([]string{"127.0.0.1"})
+	targets := NewTargets(prefixes, []uint16{openPort, closedPort})
+	limiter := throttle.New(throttle.Limits{HostConcurrency: 1})
+	scanner := NewScanner(checker,
+		WithRate(1000),
+		WithConcurrency(2),
+		WithTimeout(time.Second),
+		WithSeed(1),
+		WithLimiter(limiter),
+	)
+
+	results := make(map[netip.AddrPort]State)
+	err = scanner.Scan(ctx, targets, func(r Result) {
+		results[r.Target] = r.State
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	localhost := netip.MustParseAddr("127.0.0.1")
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %v", results)
+	}
+	if s := results[netip.AddrPortFrom(localhost, openPort)]; s != StateOpen {
+		t.Fatalf("expected open, got %s", s)
+	}
+	if s := results[netip.AddrPortFrom(localhost, closedPort)]; s != StateClosed {
+		t.Fatalf("expected closed, got %s", s)
+	}
+	if limiter.Stats().Acquired != 2 {
+		t.Fatalf("checks are not limited: %+v", limiter.Stats())
+	}
+}
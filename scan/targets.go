@@ -0,0 +1,181 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxHostBits limits the size of a single prefix to 2^32 addresses.
+const maxHostBits = 32
+
+// ParsePorts parses a comma separated list of ports and port ranges,
+// e.g. "22,80,8000-8100". Duplicated ports are removed, the result is sorted.
+func ParsePorts(s string) ([]uint16, error) {
+	seen := make(map[uint16]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := parsePort(lo)
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if isRange {
+			if last, err = parsePort(hi); err != nil {
+				return nil, err
+			}
+			if last < first {
+				return nil, fmt.Errorf("invalid port range '%s'", part)
+			}
+		}
+		for p := int(first); p <= int(last); p++ {
+			seen[uint16(p)] = true
+		}
+	}
+	if len(seen) == 0 {
+		return nil, errors.New("no port given")
+	}
+	ports := make([]uint16, 0, len(seen))
+	for p := range seen {
+		ports = append(ports, p)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+	return ports, nil
+}
+
+func parsePort(s string) (uint16, error) {
+	p, err := strconv.ParseUint(strings.TrimSpace(s), 10, 16)
+	if err != nil || p == 0 {
+		return 0, fmt.Errorf("invalid port '%s'", s)
+	}
+	return uint16(p), nil
+}
+
+// ParsePrefixes parses CIDR blocks, a single address is treated as a
+// prefix containing only itself, e.g. "10.0.0.0/24" or "192.168.1.1".
+func ParsePrefixes(specs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(specs))
+	for _, spec := range specs {
+		var prefix netip.Prefix
+		if strings.Contains(spec, "/") {
+			p, err := netip.ParsePrefix(spec)
+			if err != nil {
+				return nil, err
+			}
+			prefix = p.Masked()
+		} else {
+			addr, err := netip.ParseAddr(spec)
+			if err != nil {
+				return nil, err
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		if prefix.Addr().BitLen()-prefix.Bits() > maxHostBits {
+			return nil, fmt.Errorf("prefix '%s' is too large", spec)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// Targets is the cartesian product of the addresses of prefixes and ports,
+// each of them is identified by an index in [0, Len()).
+type Targets struct {
+	prefixes []netip.Prefix
+	// ends[i] is the number of addresses in prefixes[:i+1]
+	ends  []uint64
+	ports []uint16
+}
+
+// NewTargets creates Targets of given prefixes and ports.
+func NewTargets(prefixes []netip.Prefix, ports []uint16) *Targets {
+	t := &Targets{prefixes: prefixes, ports: ports, ends: make([]uint64, len(prefixes))}
+	var total uint64
+	for i, prefix := range prefixes {
+		total += uint64(1) << uint(prefix.Addr().BitLen()-prefix.Bits())
+		t.ends[i] = total
+	}
+	return t
+}
+
+// Len returns the number of targets.
+func (t *Targets) Len() uint64 {
+	if len(t.ends) == 0 {
+		return 0
+	}
+	return t.ends[len(t.ends)-1] * uint64(len(t.ports))
+}
+
+// At returns the target at index i.
+func (t *Targets) At(i uint64) netip.AddrPort {
+	host, port := i/uint64(len(t.ports)), t.ports[i%uint64(len(t.ports))]
+	p := sort.Search(len(t.ends), func(j int) bool { return t.ends[j] > host })
+	if p > 0 {
+		host -= t.ends[p-1]
+	}
+	return netip.AddrPortFrom(addAddr(t.prefixes[p].Addr(), host), port)
+}
+
+// addAddr returns the address n after addr.
+func addAddr(addr netip.Addr, n uint64) netip.Addr {
+	b := addr.As16()
+	var carry uint64
+	for i := 15; i >= 0 && (n > 0 || carry > 0); i-- {
+		sum := uint64(b[i]) + n&0xff + carry
+		b[i] = byte(sum)
+		carry = sum >> 8
+		n >>= 8
+	}
+	result := netip.AddrFrom16(b)
+	if addr.Is4() {
+		return result.Unmap()
+	}
+	return result
+}
+
+// permutation iterates [0, n) in a pseudo random order without
+// materializing it, by walking a full period linear congruential generator
+// modulo the next power of two and skipping values not less than n.
+type permutation struct {
+	n, mask uint64
+	a, c    uint64
+	x       uint64
+	emitted uint64
+}
+
+func newPermutation(n uint64, r *rand.Rand) *permutation {
+	m := uint64(1)
+	if n > 1 {
+		m = uint64(1) << uint(bits.Len64(n-1))
+	}
+	// Hull-Dobell theorem: c odd and a-1 divisible by 4 give a full period modulo 2^k.
+	return &permutation{
+		n:    n,
+		mask: m - 1,
+		a:    r.Uint64()&^3 | 1,
+		c:    r.Uint64() | 1,
+		x:    r.Uint64() & (m - 1),
+	}
+}
+
+// next returns the next index, false is returned once all of them are returned.
+func (p *permutation) next() (uint64, bool) {
+	for p.emitted < p.n {
+		x := p.x
+		p.x = (p.a*p.x + p.c) & p.mask
+		if x < p.n {
+			p.emitted++
+			return x, true
+		}
+	}
+	return 0, false
+}
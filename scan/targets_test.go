@@ -0,0 +1,102 @@
+package scan
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	ports, err := ParsePorts("80, 22,8000-8002,80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []uint16{22, 80, 8000, 8001, 8002}
+	if len(ports) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, ports)
+	}
+	for i := range expected {
+		if ports[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, ports)
+		}
+	}
+
+	for _, invalid := range []string{"", "0", "65536", "a", "10-1", "1-b"} {
+		if _, err := ParsePorts(invalid); err == nil {
+			t.Fatalf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	prefixes, err := ParsePrefixes([]string{"10.0.0.1/24", "192.168.1.1", "fd00::/120"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"10.0.0.0/24", "192.168.1.1/32", "fd00::/120"}
+	for i := range expected {
+		if prefixes[i].String() != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, prefixes)
+		}
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "example.com", "fd00::/64"} {
+		if _, err := ParsePrefixes([]string{invalid}); err == nil {
+			t.Fatalf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestTargets(t *testing.T) {
+	prefixes, _ := ParsePrefixes([]string{"10.0.0.254/31", "10.0.1.255", "fd00::fe/127"})
+	targets := NewTargets(prefixes, []uint16{22, 80})
+	if targets.Len() != 10 {
+		t.Fatalf("expected 10 targets, got %d", targets.Len())
+	}
+	expected := []string{
+		"10.0.0.254:22", "10.0.0.254:80",
+		"10.0.0.255:22", "10.0.0.255:80",
+		"10.0.1.255:22", "10.0.1.255:80",
+		"[fd00::fe]:22", "[fd00::fe]:80",
+		"[fd00::ff]:22", "[fd00::ff]:80",
+	}
+	for i, e := range expected {
+		if got := targets.At(uint64(i)).String(); got != e {
+			t.Fatalf("target %d: expected %s, got %s", i, e, got)
+		}
+	}
+}
+
+func TestAddAddr(t *testing.T) {
+	addr := addAddr(netip.MustParseAddr("10.0.0.255"), 257)
+	if addr.String() != "10.0.2.0" {
+		t.Fatalf("unexpected %s", addr)
+	}
+}
+
+func TestPermutation(t *testing.T) {
+	for _, n := range []uint64{0, 1, 2, 3, 1000, 1025} {
+		perm := newPermutation(n, rand.New(rand.NewSource(int64(n))))
+		seen := make(map[uint64]bool)
+		inOrder := true
+		for {
+			i, ok := perm.next()
+			if !ok {
+				break
+			}
+			if i >= n || seen[i] {
+				t.Fatalf("n=%d: unexpected index %d", n, i)
+			}
+			if i != uint64(len(seen)) {
+				inOrder = false
+			}
+			seen[i] = true
+		}
+		if uint64(len(seen)) != n {
+			t.Fatalf("n=%d: got %d indexes", n, len(seen))
+		}
+		if n > 100 && inOrder {
+			t.Fatalf("n=%d: indexes are not shuffled", n)
+		}
+	}
+}
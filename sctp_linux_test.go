@@ -0,0 +1,65 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// listenSCTP listens on a random SCTP port of 127.0.0.1, the test is skipped
+// if SCTP is not supported, e.g. the sctp kernel module is not loaded.
+func listenSCTP(t *testing.T) (fd int, port int) {
+	t.Helper()
+	fd, err := _createProtoSocket(unix.AF_INET, unix.IPPROTO_SCTP)
+	if err != nil {
+		t.Skipf("SCTP is not available: %v", err)
+	}
+	t.Cleanup(func() { unix.Close(fd) })
+	if err := unix.Bind(fd, &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Listen(fd, 1); err != nil {
+		t.Fatal(err)
+	}
+	sa, err := unix.Getsockname(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd, sa.(*unix.SockaddrInet4).Port
+}
+
+func TestCheckAddrSCTP(t *testing.T) {
+	fd, port := listenSCTP(t)
+	addr := &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}, Port: port}
+	target := sockaddrToTCPAddr(addr).String()
+
+	c := NewChecker(WithMode(ModeSCTP))
+	ctx, cancel := context.WithCancel(context.Background())
+	go c.CheckingLoop(ctx)
+	defer cancel()
+	<-c.WaitReady()
+
+	checkCtx, checkCancel := context.WithTimeout(ctx, time.Second)
+	defer checkCancel()
+	if err := c.CheckAddrContext(checkCtx, target); err != nil {
+		t.Fatalf("expected open port, got %v", err)
+	}
+
+	// The network "sctp" is checked in ModeSCTP regardless of WithMode.
+	tcpChecker := NewChecker()
+	go tcpChecker.CheckingLoop(ctx)
+	<-tcpChecker.WaitReady()
+	if err := tcpChecker.CheckNetwork(checkCtx, "sctp", target); err != nil {
+		t.Fatalf("expected open port checked by network sctp, got %v", err)
+	}
+
+	unix.Close(fd)
+	err := c.CheckAddrContext(checkCtx, target)
+	var errConnect *ErrConnect
+	if !errors.As(err, &errConnect) || !errors.Is(err, unix.ECONNREFUSED) {
+		t.Fatalf("expected ErrConnect(ECONNREFUSED), got %v", err)
+	}
+}
@@ -14,8 +14,18 @@ const maxEpollEvents = 32
 
 // createSocket creates a socket with necessary options set.
 func createSocketZeroLinger(family int, zeroLinger bool) (fd int, err error) {
+	return createSocketMode(family, ModeHalfOpen, zeroLinger)
+}
+
+// createSocketMode creates a socket with the options of given mode set,
+// the final ACK is delayed only in ModeHalfOpen and ModeBacklog.
+func createSocketMode(family int, mode Mode, zeroLinger bool) (fd int, err error) {
+	proto := unix.IPPROTO_TCP
+	if mode == ModeSCTP {
+		proto = unix.IPPROTO_SCTP
+	}
 	// Create socket
-	fd, err = _createNonBlockingSocket(family)
+	fd, err = _createNonBlockingSocket(family, proto, mode == ModeHalfOpen || mode == ModeBacklog)
 	if err == nil {
 		if zeroLinger {
 			err = _setZeroLinger(fd)
@@ -25,14 +35,14 @@ func createSocketZeroLinger(family int, zeroLinger bool) (fd int, err error) {
 }
 
 // createNonBlockingSocket creates a non-blocking socket with necessary options all set.
-func _createNonBlockingSocket(family int) (int, error) {
+func _createNonBlockingSocket(family, proto int, delayACK bool) (int, error) {
 	// Create socket
-	fd, err := _createSocket(family)
+	fd, err := _createProtoSocket(family, proto)
 	if err != nil {
 		return 0, err
 	}
 	// Set necessary options
-	err = _setSockOpts(fd)
+	err = _setSockOpts(fd, delayACK)
 	if err != nil {
 		unix.Close(fd)
 	}
@@ -41,15 +51,23 @@ func _createNonBlockingSocket(family int) (int, error) {
 
 // createSocket creates a socket with CloseOnExec set
 func _createSocket(family int) (int, error) {
-	fd, err := unix.Socket(family, unix.SOCK_STREAM, 0)
-	unix.CloseOnExec(fd)
-	return fd, err
+	return _createProtoSocket(family, unix.IPPROTO_TCP)
 }
 
-// setSockOpts sets SOCK_NONBLOCK and TCP_QUICKACK for given fd
-func _setSockOpts(fd int) error {
-	err := unix.SetNonblock(fd, true)
+// createProtoSocket creates a stream socket of given protocol with CloseOnExec set,
+// e.g. IPPROTO_SCTP.
+func _createProtoSocket(family, proto int) (int, error) {
+	fd, err := unix.Socket(family, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, proto)
 	if err != nil {
+		return -1, err
+	}
+	return fd, nil
+}
+
+// setSockOpts sets SOCK_NONBLOCK for given fd, TCP_QUICKACK is disabled if delayACK is true.
+func _setSockOpts(fd int, delayACK bool) error {
+	err := unix.SetNonblock(fd, true)
+	if err != nil || !delayACK {
 		return err
 	}
 	return unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_QUICKACK, 0)
@@ -81,6 +99,17 @@ func registerEvents(pollerFd int, fd int) error {
 	return nil
 }
 
+// registerReadEvents registers given fd with read events.
+func registerReadEvents(pollerFd int, fd int) error {
+	var event unix.EpollEvent
+	event.Events = unix.EPOLLIN | unix.EPOLLET
+	event.Fd = int32(fd)
+	if err := unix.EpollCtl(pollerFd, unix.EPOLL_CTL_ADD, fd, &event); err != nil {
+		return os.NewSyscallError(fmt.Sprintf("epoll_ctl(%d, ADD, %d, ...)", pollerFd, fd), err)
+	}
+	return nil
+}
+
 func pollEvents(pollerFd int, timeout time.Duration) ([]internal.Event, error) {
 	var timeoutMS = int(timeout.Nanoseconds() / 1000000)
 	var epollEvents [maxEpollEvents]unix.EpollEvent
@@ -14,26 +14,52 @@ func parseSockAddr(addr string) (sAddr unix.Sockaddr, family int, err error) {
 	if err != nil {
 		return
 	}
+	return toSockAddr(tAddr.IP, tAddr.Port)
+}
 
-	if ip := tAddr.IP.To4(); ip != nil {
+// parseUDPSockAddr is like parseSockAddr but the port is resolved for UDP.
+func parseUDPSockAddr(addr string) (sAddr unix.Sockaddr, family int, err error) {
+	uAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return
+	}
+	return toSockAddr(uAddr.IP, uAddr.Port)
+}
+
+func toSockAddr(ip net.IP, port int) (sAddr unix.Sockaddr, family int, err error) {
+	if ip4 := ip.To4(); ip4 != nil {
 		var addr4 [net.IPv4len]byte
-		copy(addr4[:], ip)
-		sAddr = &unix.SockaddrInet4{Port: tAddr.Port, Addr: addr4}
+		copy(addr4[:], ip4)
+		sAddr = &unix.SockaddrInet4{Port: port, Addr: addr4}
 		family = unix.AF_INET
 		return
 	}
 
-	if ip := tAddr.IP.To16(); ip != nil {
+	if ip16 := ip.To16(); ip16 != nil {
 		var addr16 [net.IPv6len]byte
-		copy(addr16[:], ip)
-		sAddr = &unix.SockaddrInet6{Port: tAddr.Port, Addr: addr16}
+		copy(addr16[:], ip16)
+		sAddr = &unix.SockaddrInet6{Port: port, Addr: addr16}
 		family = unix.AF_INET6
 		return
 	}
 
 	err = &net.AddrError{
 		Err:  "unsupported address family",
-		Addr: tAddr.IP.String(),
+		Addr: ip.String(),
 	}
 	return
 }
+
+// sockaddrToTCPAddr converts given unix.Sockaddr to *net.TCPAddr,
+// nil is returned if the address family is not supported.
+func sockaddrToTCPAddr(sAddr unix.Sockaddr) *net.TCPAddr {
+	switch sa := sAddr.(type) {
+	case *unix.SockaddrInet4:
+		return &net.TCPAddr{IP: net.IPv4(sa.Addr[0], sa.Addr[1], sa.Addr[2], sa.Addr[3]), Port: sa.Port}
+	case *unix.SockaddrInet6:
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, sa.Addr[:])
+		return &net.TCPAddr{IP: ip, Port: sa.Port}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package tcp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"syscall"
+)
+
+// ErrLocalResource indicates a check failed due to the exhaustion of a local
+// resource rather than a failure of the target, e.g. EADDRNOTAVAIL returned
+// by connect once the ephemeral ports are exhausted. See WithSourcePool.
+type ErrLocalResource struct {
+	error
+}
+
+// Unwrap returns the underlying error, e.g. syscall.EADDRNOTAVAIL.
+func (e *ErrLocalResource) Unwrap() error { return e.error }
+
+// isLocalErrno returns whether errno indicates the exhaustion of a local resource.
+func isLocalErrno(errno syscall.Errno) bool {
+	switch errno {
+	case syscall.EADDRNOTAVAIL, syscall.EADDRINUSE, syscall.EMFILE, syscall.ENFILE, syscall.ENOBUFS:
+		return true
+	}
+	return false
+}
+
+// localError returns err as ErrLocalResource if it indicates the exhaustion
+// of a local resource, otherwise nil.
+func localError(err error) *ErrLocalResource {
+	var errno syscall.Errno
+	if errors.As(err, &errno) && isLocalErrno(errno) {
+		return &ErrLocalResource{err}
+	}
+	return nil
+}
+
+// localOrConnectError wraps the error of connect as ErrLocalResource or ErrConnect.
+func localOrConnectError(err error) error {
+	if localErr := localError(err); localErr != nil {
+		return localErr
+	}
+	return &ErrConnect{err}
+}
+
+// LocalStats are the counters of the failures due to local resources.
+type LocalStats struct {
+	// LocalErrors is the number of checks failed with ErrLocalResource.
+	LocalErrors uint64
+	// AddrNotAvail is the number of checks failed with EADDRNOTAVAIL,
+	// which are counted in LocalErrors too.
+	AddrNotAvail uint64
+	// BindRetries is the number of times an address of the SourcePool was in
+	// use and the next one was tried.
+	BindRetries uint64
+}
+
+// localStats is the atomic version of LocalStats.
+type localStats struct {
+	localErrors  atomic.Uint64
+	addrNotAvail atomic.Uint64
+	bindRetries  atomic.Uint64
+}
+
+// count counts err if it is an ErrLocalResource.
+func (s *localStats) count(err error) {
+	var localErr *ErrLocalResource
+	if !errors.As(err, &localErr) {
+		return
+	}
+	s.localErrors.Add(1)
+	if errors.Is(err, syscall.EADDRNOTAVAIL) {
+		s.addrNotAvail.Add(1)
+	}
+}
+
+func (s *localStats) snapshot() LocalStats {
+	return LocalStats{
+		LocalErrors:  s.localErrors.Load(),
+		AddrNotAvail: s.addrNotAvail.Load(),
+		BindRetries:  s.bindRetries.Load(),
+	}
+}
+
+// LocalStats returns the counters of the failures due to local resources.
+func (c *Checker) LocalStats() LocalStats {
+	return c.localStats.snapshot()
+}
+
+// maxBindAttempts is the maximum number of addresses of the SourcePool
+// tried by a check before giving up with EADDRINUSE.
+const maxBindAttempts = 8
+
+// SourcePool cycles the source addresses of checks, which keeps checks
+// working under sustained load from a single IP whose ephemeral ports are exhausted.
+// It is safe for concurrent use.
+type SourcePool struct {
+	ips4, ips6          []netip.Addr
+	firstPort, lastPort uint16
+	next                atomic.Uint64
+}
+
+// NewSourcePool creates a SourcePool cycling given IPs and the ports from
+// firstPort to lastPort, both inclusive. If no IP of the family of the target
+// is given, the kernel chooses the source IP. If the ports are zero,
+// the kernel chooses ephemeral ports of the source IPs.
+// An error is returned if an IP is not an address of this host.
+func NewSourcePool(ips []netip.Addr, firstPort, lastPort uint16) (*SourcePool, error) {
+	p, err := newSourcePool(ips, firstPort, lastPort)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range append(p.ips4, p.ips6...) {
+		// Binding an address which is not of this host fails with EADDRNOTAVAIL.
+		conn, err := net.ListenUDP("udp", net.UDPAddrFromAddrPort(netip.AddrPortFrom(ip, 0)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid source IP '%s': %w", ip, err)
+		}
+		conn.Close()
+	}
+	return p, nil
+}
+
+// newSourcePool is NewSourcePool without checking the IPs are of this host.
+func newSourcePool(ips []netip.Addr, firstPort, lastPort uint16) (*SourcePool, error) {
+	if firstPort > lastPort || (firstPort == 0 && lastPort != 0) {
+		return nil, fmt.Errorf("invalid source port range %d-%d", firstPort, lastPort)
+	}
+	if len(ips) == 0 && firstPort == 0 {
+		return nil, errors.New("either source IPs or source ports are required")
+	}
+	p := &SourcePool{firstPort: firstPort, lastPort: lastPort}
+	for _, ip := range ips {
+		switch ip = ip.Unmap(); {
+		case ip.Is4():
+			p.ips4 = append(p.ips4, ip)
+		case ip.Is6():
+			p.ips6 = append(p.ips6, ip)
+		default:
+			return nil, fmt.Errorf("invalid source IP '%s'", ip)
+		}
+	}
+	return p, nil
+}
+
+// size returns the number of addresses of the family.
+func (p *SourcePool) size(is4 bool) int {
+	ips := p.ips(is4)
+	n := max(len(ips), 1)
+	if p.firstPort != 0 {
+		n *= int(p.lastPort-p.firstPort) + 1
+	}
+	return n
+}
+
+func (p *SourcePool) ips(is4 bool) []netip.Addr {
+	if is4 {
+		return p.ips4
+	}
+	return p.ips6
+}
+
+// nextAddr returns the next source address of the family, ok is false if
+// there is nothing to bind, i.e. no IP of the family and no port.
+func (p *SourcePool) nextAddr(is4 bool) (addr netip.AddrPort, ok bool) {
+	ips := p.ips(is4)
+	if len(ips) == 0 && p.firstPort == 0 {
+		return netip.AddrPort{}, false
+	}
+	n := p.next.Add(1) - 1
+	ip := netip.IPv6Unspecified()
+	if is4 {
+		ip = netip.IPv4Unspecified()
+	}
+	if len(ips) > 0 {
+		ip = ips[n%uint64(len(ips))]
+		n /= uint64(len(ips))
+	}
+	var port uint16
+	if p.firstPort != 0 {
+		port = p.firstPort + uint16(n%(uint64(p.lastPort-p.firstPort)+1))
+	}
+	return netip.AddrPortFrom(ip, port), true
+}
+
+// WithSourcePool makes the checks bind their sockets to the addresses of
+// pool in turn. If an address is in use, the next one is tried, and
+// ErrLocalResource is returned once the attempts are exhausted. The other
+// failures of binding, e.g. EADDRNOTAVAIL of an IP removed from the host,
+// are errors of the configuration rather than ErrLocalResource.
+// It does not apply to the checks in raw SYN mode, of UDP or Unix domain sockets.
+func WithSourcePool(pool *SourcePool) Option {
+	return func(c *config) {
+		c.sourcePool = pool
+	}
+}
@@ -0,0 +1,61 @@
+package tcp
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// bindSource binds fd to the next address of the SourcePool, if any.
+func (c *Checker) bindSource(fd, family int) error {
+	pool := c.sourcePool
+	is4 := family == unix.AF_INET
+	attempts := min(pool.size(is4), maxBindAttempts)
+	for i := 0; ; i++ {
+		src, ok := pool.nextAddr(is4)
+		if !ok {
+			return nil
+		}
+		err := bindAddr(fd, src)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, unix.EADDRINUSE) {
+			// Not the exhaustion of the pool but a misconfiguration,
+			// e.g. an IP which is no longer of this host.
+			return fmt.Errorf("binding source address %s: %w", src, err)
+		}
+		if i+1 >= attempts {
+			return &ErrLocalResource{err}
+		}
+		c.localStats.bindRetries.Add(1)
+	}
+}
+
+// bindAddr binds fd to src. With port 0, the port is chosen at connect time
+// so that it is shared by the connections to different destinations.
+func bindAddr(fd int, src netip.AddrPort) error {
+	var err error
+	if src.Port() == 0 {
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_BIND_ADDRESS_NO_PORT, 1)
+	} else {
+		// The port could be in TIME_WAIT if linger is not zero.
+		err = unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEADDR, 1)
+	}
+	if err != nil {
+		return os.NewSyscallError("setsockopt", err)
+	}
+	var sa unix.Sockaddr
+	if src.Addr().Is4() {
+		sa = &unix.SockaddrInet4{Addr: src.Addr().As4(), Port: int(src.Port())}
+	} else {
+		sa = &unix.SockaddrInet6{Addr: src.Addr().As16(), Port: int(src.Port())}
+	}
+	if err := unix.Bind(fd, sa); err != nil {
+		return os.NewSyscallError("bind", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestCheckAddrSourcePool(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// A free port range of 2 ports.
+	probe, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := uint16(probe.Addr().(*net.TCPAddr).Port)
+	probe.Close()
+	pool, err := NewSourcePool([]netip.Addr{netip.MustParseAddr("127.0.0.1")}, first, first+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewChecker(WithMode(ModeConnect), WithSourcePool(pool))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	for i := 0; i < 2; i++ {
+		if err := c.CheckAddr(l.Addr().String(), time.Second); err != nil {
+			t.Fatalf("expected success, got %v", err)
+		}
+		conn, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		port := uint16(conn.RemoteAddr().(*net.TCPAddr).Port)
+		conn.Close()
+		if port != first+uint16(i) {
+			t.Fatalf("expected source port %d, got %d", first+uint16(i), port)
+		}
+	}
+
+	// A connection with the same 4-tuple exists: EADDRNOTAVAIL.
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fd)
+	_ = unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEADDR, 1)
+	if err := unix.Bind(fd, &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}, Port: int(first)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Connect(fd, &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}, Port: l.Addr().(*net.TCPAddr).Port}); err != nil {
+		t.Fatal(err)
+	}
+	err = c.CheckAddr(l.Addr().String(), time.Second)
+	var localErr *ErrLocalResource
+	if !errors.As(err, &localErr) || !errors.Is(err, syscall.EADDRNOTAVAIL) {
+		t.Fatalf("expected ErrLocalResource(EADDRNOTAVAIL), got %v", err)
+	}
+	if stats := c.LocalStats(); stats.LocalErrors != 1 || stats.AddrNotAvail != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCheckAddrSourcePoolBindRetry(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// The first port of the pool is in use by a listener.
+	busy, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	first := uint16(busy.Addr().(*net.TCPAddr).Port)
+	pool, err := NewSourcePool(nil, first, first+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewChecker(WithSourcePool(pool))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	if err := c.CheckAddr(l.Addr().String(), time.Second); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if stats := c.LocalStats(); stats.BindRetries != 1 {
+		t.Fatalf("expected a bind retry, got %+v", stats)
+	}
+}
+
+func TestCheckAddrSourcePoolMisconfigured(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// An IP which is not of this host, e.g. removed after the pool is created.
+	pool, err := newSourcePool([]netip.Addr{netip.MustParseAddr("192.0.2.1")}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewChecker(WithSourcePool(pool))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	err = c.CheckAddr(l.Addr().String(), time.Second)
+	var localErr *ErrLocalResource
+	if errors.As(err, &localErr) || !errors.Is(err, syscall.EADDRNOTAVAIL) {
+		t.Fatalf("expected EADDRNOTAVAIL not being ErrLocalResource, got %v", err)
+	}
+	if stats := c.LocalStats(); stats.LocalErrors != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,100 @@
+package tcp
+
+import (
+	"errors"
+	"net/netip"
+	"syscall"
+	"testing"
+)
+
+func TestSourcePool(t *testing.T) {
+	ip4a, ip4b := netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2")
+	ip6 := netip.MustParseAddr("2001:db8::1")
+	pool, err := newSourcePool([]netip.Addr{ip4a, ip6, ip4b}, 40000, 40001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var addrs []string
+	for i := 0; i < 5; i++ {
+		addr, ok := pool.nextAddr(true)
+		if !ok {
+			t.Fatal("expected an address")
+		}
+		addrs = append(addrs, addr.String())
+	}
+	expected := []string{"192.0.2.1:40000", "192.0.2.2:40000", "192.0.2.1:40001", "192.0.2.2:40001", "192.0.2.1:40000"}
+	for i := range expected {
+		if addrs[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, addrs)
+		}
+	}
+	if addr, _ := pool.nextAddr(false); addr.Addr() != ip6 {
+		t.Fatalf("expected an IPv6 address, got %s", addr)
+	}
+	if pool.size(true) != 4 || pool.size(false) != 2 {
+		t.Fatalf("unexpected sizes %d and %d", pool.size(true), pool.size(false))
+	}
+
+	// The kernel chooses the IPs of the families without an IP.
+	pool, err = newSourcePool(nil, 40000, 40009)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr, _ := pool.nextAddr(false); addr != netip.MustParseAddrPort("[::]:40000") {
+		t.Fatalf("unexpected address %s", addr)
+	}
+	// The kernel chooses the ports without a port range.
+	pool, err = newSourcePool([]netip.Addr{ip4a}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr, ok := pool.nextAddr(true); !ok || addr != netip.AddrPortFrom(ip4a, 0) {
+		t.Fatalf("unexpected address %s", addr)
+	}
+	if _, ok := pool.nextAddr(false); ok {
+		t.Fatal("expected nothing to bind")
+	}
+
+	for _, c := range []struct {
+		ips         []netip.Addr
+		first, last uint16
+	}{
+		{nil, 0, 0},
+		{nil, 2, 1},
+		{nil, 0, 1},
+		{[]netip.Addr{{}}, 0, 0},
+	} {
+		if _, err := NewSourcePool(c.ips, c.first, c.last); err == nil {
+			t.Errorf("expected error of %v %d-%d", c.ips, c.first, c.last)
+		}
+	}
+
+	// The IPs must be of this host.
+	if _, err := NewSourcePool([]netip.Addr{netip.MustParseAddr("127.0.0.1")}, 0, 0); err != nil {
+		t.Fatalf("expected a pool of a local IP, got %v", err)
+	}
+	if _, err := NewSourcePool([]netip.Addr{ip4a}, 0, 0); err == nil {
+		t.Fatal("expected error of a foreign IP")
+	}
+}
+
+func TestLocalOrConnectError(t *testing.T) {
+	var localErr *ErrLocalResource
+	var connectErr *ErrConnect
+	for _, errno := range []syscall.Errno{syscall.EADDRNOTAVAIL, syscall.EADDRINUSE, syscall.EMFILE} {
+		if err := localOrConnectError(errno); !errors.As(err, &localErr) || !errors.Is(err, errno) {
+			t.Errorf("expected ErrLocalResource(%v), got %v", errno, err)
+		}
+	}
+	if err := localOrConnectError(syscall.ECONNREFUSED); !errors.As(err, &connectErr) {
+		t.Errorf("expected ErrConnect, got %v", err)
+	}
+
+	var stats localStats
+	stats.count(&ErrLocalResource{syscall.EADDRNOTAVAIL})
+	stats.count(&ErrLocalResource{syscall.EMFILE})
+	stats.count(&ErrConnect{syscall.ECONNREFUSED})
+	if s := stats.snapshot(); s.LocalErrors != 2 || s.AddrNotAvail != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
@@ -0,0 +1,57 @@
+package tcp
+
+import "time"
+
+// Supervisor contains the options of supervising CheckingLoop, see WithSupervisor.
+type Supervisor struct {
+	// InitialBackoff is the delay before the first restart, 100ms if zero.
+	InitialBackoff time.Duration
+	// MaxBackoff is the upper bound of the delay between restarts, 30s if zero.
+	// The delay doubles after each failed attempt and is reset once the
+	// loop has been running for longer than MaxBackoff.
+	MaxBackoff time.Duration
+	// MaxRestarts is the maximum number of restarts of a run of CheckingLoop,
+	// 0 means unlimited. CheckingLoop returns the last error once it is exceeded.
+	MaxRestarts int
+}
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+func (s *Supervisor) withDefaults() *Supervisor {
+	sup := *s
+	if sup.InitialBackoff <= 0 {
+		sup.InitialBackoff = defaultInitialBackoff
+	}
+	if sup.MaxBackoff <= 0 {
+		sup.MaxBackoff = defaultMaxBackoff
+	}
+	if sup.MaxBackoff < sup.InitialBackoff {
+		sup.MaxBackoff = sup.InitialBackoff
+	}
+	return &sup
+}
+
+// nextBackoff returns the delay following given one.
+func (s *Supervisor) nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > s.MaxBackoff {
+		return s.MaxBackoff
+	}
+	return backoff
+}
+
+// LoopStatus is the status of CheckingLoop.
+type LoopStatus struct {
+	// Running indicates whether the Checker is ready for use.
+	Running bool
+	// Restarts is the number of times the poller was recreated by the supervisor,
+	// it accumulates across the runs of CheckingLoop.
+	Restarts uint64
+	// LastError is the last fatal error occurred in CheckingLoop.
+	LastError error
+	// LastErrorAt is the time when LastError occurred.
+	LastErrorAt time.Time
+}
@@ -0,0 +1,128 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// supervisedPollingLoop runs pollingLoop and recreates the poller on fatal errors.
+func (c *Checker) supervisedPollingLoop(ctx context.Context, pollerFd int) error {
+	sup := c.supervisor
+	backoff := sup.InitialBackoff
+	startedAt := time.Now()
+	// restarts is the number of restarts of this run, see Supervisor.MaxRestarts.
+	restarts := 0
+	for {
+		err := c.pollingLoop(ctx, pollerFd)
+		if err == nil {
+			return nil
+		}
+		if time.Since(startedAt) > sup.MaxBackoff {
+			// the loop was healthy for a while
+			backoff = sup.InitialBackoff
+		}
+
+		for {
+			if sup.MaxRestarts > 0 && restarts >= sup.MaxRestarts {
+				c.log().Error("tcpshaker: too many restarts of checking loop", "restarts", restarts, "error", err)
+				return err
+			}
+			c.log().Warn("tcpshaker: restarting checking loop", "backoff", backoff, "error", err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+			backoff = sup.nextBackoff(backoff)
+
+			pollerFd, err = c.restartPoller()
+			restarts++
+			c.incRestarts()
+			if err == nil {
+				break
+			}
+			c.setLastError(err)
+		}
+		startedAt = time.Now()
+	}
+}
+
+// restartPoller replaces the poller with a new one and registers the pending fds to it.
+// The new poller is ready before it is published and the old one is closed
+// afterwards, so that the concurrent checks never see a closed poller.
+func (c *Checker) restartPoller() (int, error) {
+	pollerFd, err := createPoller()
+	if err != nil {
+		return -1, fmt.Errorf("error recreating poller: %w", err)
+	}
+	if prober := c.synProber.Load(); prober != nil {
+		if err := prober.register(pollerFd); err != nil {
+			_ = unix.Close(pollerFd)
+			return -1, err
+		}
+	}
+	c.registerPending(pollerFd)
+
+	c.pollerLock.Lock()
+	oldFd := c.pollerFD()
+	c.setPollerFD(pollerFd)
+	c.pollerLock.Unlock()
+	if oldFd > 0 {
+		_ = unix.Close(oldFd)
+	}
+	// The checks registered to the old poller during the swap.
+	c.registerPending(pollerFd)
+	return pollerFd, nil
+}
+
+// registerPending registers the fds of the pending checks to pollerFd,
+// the ones registered already are skipped.
+func (c *Checker) registerPending(pollerFd int) {
+	c.resultPipes.Range(func(fd int, _ chan error) bool {
+		register := registerEvents
+		if isDatagram(fd) {
+			// UDP sockets are always writable, see checkUDP.
+			register = registerReadEvents
+		}
+		if err := register(pollerFd, fd); err != nil && !errors.Is(err, unix.EEXIST) {
+			// fail the pending check
+			if pipe, exists := c.resultPipes.PopResultPipe(fd); exists {
+				pipe <- err
+			}
+		}
+		return true
+	})
+}
+
+// isDatagram returns whether fd is a datagram socket.
+func isDatagram(fd int) bool {
+	typ, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_TYPE)
+	return err == nil && typ == unix.SOCK_DGRAM
+}
+
+func (c *Checker) incRestarts() {
+	c.statusLock.Lock()
+	c.status.Restarts++
+	c.statusLock.Unlock()
+}
+
+func (c *Checker) setLastError(err error) {
+	c.statusLock.Lock()
+	c.status.LastError = err
+	c.status.LastErrorAt = time.Now()
+	c.statusLock.Unlock()
+}
+
+// LoopStatus returns the status of CheckingLoop, including the restarts made
+// by the supervisor and the last fatal error.
+func (c *Checker) LoopStatus() LoopStatus {
+	c.statusLock.Lock()
+	status := c.status
+	c.statusLock.Unlock()
+	status.Running = c.IsReady()
+	return status
+}
@@ -0,0 +1,199 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// breakPoller replaces the poller of c with a non-epoll fd to make the polling loop fail.
+func breakPoller(t *testing.T, c *Checker) {
+	fd, err := unix.Socket(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fd)
+	if err := unix.Dup2(fd, c.PollerFd()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSupervisorRestartsPoller(t *testing.T) {
+	c := NewChecker(WithSupervisor(Supervisor{InitialBackoff: time.Millisecond * 10}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	loopStopped := make(chan error, 1)
+	go func() {
+		loopStopped <- c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	addr, stop := StartTestServer()
+	defer stop()
+
+	// A pending check whose fd is not registered to the new poller yet.
+	rAddr, family, err := parseSockAddr(addr)
+	assert(t, err == nil)
+	fd, err := createSocketZeroLinger(family, true)
+	assert(t, err == nil)
+	defer unix.Close(fd)
+	pipe := make(chan error, 1)
+	c.resultPipes.RegisterResultPipe(fd, pipe)
+	_, err = connect(fd, rAddr)
+	assert(t, err == nil)
+
+	breakPoller(t, c)
+
+	select {
+	case err := <-pipe:
+		assert(t, err == nil)
+	case err := <-loopStopped:
+		t.Fatalf("checking loop stopped: %v", err)
+	case <-time.After(pollerTimeout * 3):
+		t.Fatal("pending check is not registered to the new poller")
+	}
+
+	status := c.LoopStatus()
+	assert(t, status.Running)
+	assert(t, status.Restarts == 1)
+	assert(t, status.LastError != nil)
+	assert(t, !status.LastErrorAt.IsZero())
+	assert(t, c.CheckAddr(addr, time.Second) == nil)
+
+	cancel()
+	assert(t, <-loopStopped == nil)
+}
+
+func TestSupervisorMaxRestarts(t *testing.T) {
+	c := NewChecker(WithSupervisor(Supervisor{InitialBackoff: time.Millisecond, MaxRestarts: 1}))
+	loopStopped := make(chan error, 1)
+	go func() {
+		loopStopped <- c.CheckingLoop(context.Background())
+	}()
+	<-c.WaitReady()
+
+	breakPoller(t, c)
+	for c.LoopStatus().Restarts < 1 {
+		time.Sleep(time.Millisecond * 10)
+	}
+	breakPoller(t, c)
+
+	select {
+	case err := <-loopStopped:
+		assert(t, err != nil)
+	case <-time.After(pollerTimeout * 3):
+		t.Fatal("checking loop did not stop after MaxRestarts exceeded")
+	}
+}
+
+// loopErrorCounter counts the calls of OnLoopError.
+type loopErrorCounter struct {
+	NopObserver
+	n atomic.Int32
+}
+
+func (o *loopErrorCounter) OnLoopError(error) { o.n.Add(1) }
+
+func TestSupervisorMaxRestartsPerRun(t *testing.T) {
+	obs := &loopErrorCounter{}
+	c := NewChecker(WithSupervisor(Supervisor{InitialBackoff: time.Millisecond, MaxRestarts: 1}), WithObserver(obs))
+	for run := 1; run <= 2; run++ {
+		loopStopped := make(chan error, 1)
+		go func() {
+			loopStopped <- c.CheckingLoop(context.Background())
+		}()
+		<-c.WaitReady()
+
+		// Every run is allowed to restart once.
+		breakPoller(t, c)
+		for c.LoopStatus().Restarts < uint64(run) {
+			time.Sleep(time.Millisecond * 10)
+		}
+		assert(t, obs.n.Load() == int32(run-1))
+		breakPoller(t, c)
+
+		select {
+		case err := <-loopStopped:
+			assert(t, err != nil)
+		case <-time.After(pollerTimeout * 3):
+			t.Fatal("checking loop did not stop after MaxRestarts exceeded")
+		}
+		// Only the error stopping CheckingLoop is reported.
+		assert(t, obs.n.Load() == int32(run))
+	}
+	assert(t, c.LoopStatus().Restarts == 2)
+}
+
+func TestRestartPollerDuringRegistration(t *testing.T) {
+	c := NewChecker()
+	_, err := c.createPoller()
+	assert(t, err == nil)
+	defer c.closePoller()
+
+	// The poller is replaced while checks are registering, none of them
+	// should fail due to a closed poller or an fd registered twice.
+	restarting, stopRestarting := context.WithCancel(context.Background())
+	restarted := make(chan struct{})
+	go func() {
+		defer close(restarted)
+		for restarting.Err() == nil {
+			_, err := c.restartPoller()
+			assert(t, err == nil)
+		}
+	}()
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		fds   []int
+		pipes []chan error
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				fd, err := unix.Socket(unix.AF_INET, unix.SOCK_STREAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, 0)
+				assert(t, err == nil)
+				pipe := make(chan error, 1)
+				c.resultPipes.RegisterResultPipe(fd, pipe)
+				if err := c.registerCheck(fd, registerEvents); err != nil {
+					t.Errorf("error registering a check during restart: %v", err)
+				}
+				mu.Lock()
+				fds, pipes = append(fds, fd), append(pipes, pipe)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	stopRestarting()
+	<-restarted
+
+	for i, fd := range fds {
+		select {
+		case err := <-pipes[i]:
+			t.Errorf("pending check failed during restart: %v", err)
+		default:
+		}
+		// Every fd is registered to the current poller.
+		if err := registerEvents(c.pollerFD(), fd); !errors.Is(err, unix.EEXIST) {
+			t.Errorf("fd %d is not registered to the current poller: %v", fd, err)
+		}
+		unix.Close(fd)
+	}
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	sup := (&Supervisor{InitialBackoff: time.Second, MaxBackoff: time.Second * 3}).withDefaults()
+	assert(t, sup.nextBackoff(time.Second) == time.Second*2)
+	assert(t, sup.nextBackoff(time.Second*2) == time.Second*3)
+
+	sup = (&Supervisor{}).withDefaults()
+	assert(t, sup.InitialBackoff == defaultInitialBackoff)
+	assert(t, sup.MaxBackoff == defaultMaxBackoff)
+}
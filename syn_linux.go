@@ -0,0 +1,252 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/netip"
+	"os"
+	"sync"
+
+	"github.com/tevino/tcp-shaker/internal/rawtcp"
+	"golang.org/x/sys/unix"
+)
+
+// errSYNProberNotRunning is returned by checks in raw SYN mode while CheckingLoop is not running.
+var errSYNProberNotRunning = errors.New("raw SYN prober is not running")
+
+// synWindow is the window announced in crafted SYNs.
+const synWindow = 64240
+
+// synKey identifies the replies to a SYN.
+type synKey struct {
+	remote    netip.AddrPort
+	localPort uint16
+}
+
+// synWaiter is a pending SYN waiting for its reply.
+type synWaiter struct {
+	local netip.Addr
+	isn   uint32
+	pipe  chan error
+}
+
+// synProber sends SYNs via raw sockets and matches the replies by addresses,
+// ports and sequence number. Raw sockets receive a copy of every TCP segment,
+// they are registered to the poller and drained by handlePollerEvents.
+type synProber struct {
+	fd4, fd6 int
+	lock     sync.Mutex
+	waiters  map[synKey]*synWaiter
+}
+
+// newSYNProber opens the raw sockets, which requires CAP_NET_RAW.
+// IPv6 is left unavailable if it is not supported by the system.
+func newSYNProber() (*synProber, error) {
+	fd4, err := createRawSocket(unix.AF_INET)
+	if err != nil {
+		return nil, err
+	}
+	fd6, err := createRawSocket(unix.AF_INET6)
+	if err != nil {
+		if !errors.Is(err, unix.EAFNOSUPPORT) {
+			unix.Close(fd4)
+			return nil, err
+		}
+		fd6 = -1
+	}
+	return &synProber{fd4: fd4, fd6: fd6, waiters: make(map[synKey]*synWaiter)}, nil
+}
+
+func createRawSocket(family int) (int, error) {
+	fd, err := unix.Socket(family, unix.SOCK_RAW|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, unix.IPPROTO_TCP)
+	if err != nil {
+		return -1, os.NewSyscallError("socket", err)
+	}
+	return fd, nil
+}
+
+// fds returns the opened raw sockets.
+func (p *synProber) fds() []int {
+	if p.fd6 < 0 {
+		return []int{p.fd4}
+	}
+	return []int{p.fd4, p.fd6}
+}
+
+// owns returns true if fd is one of the raw sockets.
+func (p *synProber) owns(fd int) bool {
+	return fd == p.fd4 || (p.fd6 >= 0 && fd == p.fd6)
+}
+
+// register registers the raw sockets to given poller.
+func (p *synProber) register(pollerFd int) error {
+	for _, fd := range p.fds() {
+		if err := registerReadEvents(pollerFd, fd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *synProber) close() {
+	for _, fd := range p.fds() {
+		unix.Close(fd)
+	}
+}
+
+// receive drains the raw socket fd and delivers the replies to waiters.
+func (p *synProber) receive(fd int) {
+	var buf [1500]byte
+	for {
+		n, from, err := unix.Recvfrom(fd, buf[:], 0)
+		if err != nil {
+			// EAGAIN: drained
+			return
+		}
+		var remote netip.Addr
+		var seg *rawtcp.Segment
+		if fd == p.fd4 {
+			remote, _, seg, err = rawtcp.ParseIPv4(buf[:n])
+		} else if sa, ok := from.(*unix.SockaddrInet6); ok {
+			remote = netip.AddrFrom16(sa.Addr)
+			seg, err = rawtcp.Parse(buf[:n])
+		} else {
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		p.deliver(remote, seg)
+	}
+}
+
+// deliver sends the result to the waiter of seg if it is a reply to the SYN.
+func (p *synProber) deliver(remote netip.Addr, seg *rawtcp.Segment) {
+	key := synKey{remote: netip.AddrPortFrom(remote, seg.SrcPort), localPort: seg.DstPort}
+	p.lock.Lock()
+	w, exists := p.waiters[key]
+	if !exists || seg.Ack != w.isn+1 || !seg.Is(rawtcp.FlagACK) {
+		p.lock.Unlock()
+		return
+	}
+	delete(p.waiters, key)
+	p.lock.Unlock()
+
+	switch {
+	case seg.Is(rawtcp.FlagRST):
+		w.pipe <- &ErrConnect{unix.ECONNREFUSED}
+	case seg.Is(rawtcp.FlagSYN):
+		// Abort the half-open connection explicitly.
+		rst := rawtcp.Segment{SrcPort: key.localPort, DstPort: key.remote.Port(), Seq: w.isn + 1, Flags: rawtcp.FlagRST}
+		_ = p.send(w.local, key.remote, &rst)
+		w.pipe <- nil
+	}
+}
+
+// send sends seg from local to remote.
+func (p *synProber) send(local netip.Addr, remote netip.AddrPort, seg *rawtcp.Segment) error {
+	b := seg.Marshal(local, remote.Addr())
+	var err error
+	if remote.Addr().Is4() {
+		err = unix.Sendto(p.fd4, b, 0, &unix.SockaddrInet4{Addr: remote.Addr().As4()})
+	} else {
+		if p.fd6 < 0 {
+			return &ErrConnect{unix.EAFNOSUPPORT}
+		}
+		err = unix.Sendto(p.fd6, b, 0, &unix.SockaddrInet6{Addr: remote.Addr().As16()})
+	}
+	if err != nil {
+		return os.NewSyscallError("sendto", err)
+	}
+	return nil
+}
+
+// localAddr returns the source address used to reach remote by
+// connecting a UDP socket, which sends nothing.
+func localAddr(family int, remote unix.Sockaddr) (netip.Addr, error) {
+	fd, err := unix.Socket(family, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return netip.Addr{}, os.NewSyscallError("socket", err)
+	}
+	defer unix.Close(fd)
+	if err := unix.Connect(fd, remote); err != nil {
+		return netip.Addr{}, &ErrConnect{err}
+	}
+	sa, err := unix.Getsockname(fd)
+	if err != nil {
+		return netip.Addr{}, os.NewSyscallError("getsockname", err)
+	}
+	return sockaddrToTCPAddr(sa).AddrPort().Addr().Unmap(), nil
+}
+
+// reservePort binds a TCP socket to an ephemeral port of local, so that the
+// kernel neither uses the port for other connections nor accepts the
+// SYN-ACKs sent to it. The socket must be closed once the check is done.
+func reservePort(family int, local netip.Addr) (fd int, port uint16, err error) {
+	fd, err = _createSocket(family)
+	if err != nil {
+		return -1, 0, os.NewSyscallError("socket", err)
+	}
+	var sa unix.Sockaddr
+	if family == unix.AF_INET6 {
+		sa = &unix.SockaddrInet6{Addr: local.As16()}
+	} else {
+		sa = &unix.SockaddrInet4{Addr: local.As4()}
+	}
+	if err = unix.Bind(fd, sa); err == nil {
+		sa, err = unix.Getsockname(fd)
+	}
+	if err != nil {
+		unix.Close(fd)
+		return -1, 0, os.NewSyscallError("bind", err)
+	}
+	return fd, uint16(sockaddrToTCPAddr(sa).Port), nil
+}
+
+// checkAddrSYN performs the check with a crafted SYN.
+func (c *Checker) checkAddrSYN(ctx context.Context, rAddr unix.Sockaddr, family int) error {
+	prober := c.synProber.Load()
+	if prober == nil {
+		return errSYNProberNotRunning
+	}
+	local, err := localAddr(family, rAddr)
+	if err != nil {
+		return err
+	}
+	fd, port, err := reservePort(family, local)
+	if err != nil {
+		if c.debugEnabled(ctx) {
+			c.log().Debug("tcpshaker: error reserving port", "local", local, "error", err)
+		}
+		return err
+	}
+	defer unix.Close(fd)
+	c.observer.OnSocketCreated(ctx, fd)
+
+	remote := sockaddrToTCPAddr(rAddr).AddrPort()
+	remote = netip.AddrPortFrom(remote.Addr().Unmap(), remote.Port())
+	key := synKey{remote: remote, localPort: port}
+	// The pipe is not pooled since a reply could be delivered after the timeout.
+	w := &synWaiter{local: local, isn: rand.Uint32(), pipe: make(chan error, 1)}
+	prober.lock.Lock()
+	prober.waiters[key] = w
+	prober.lock.Unlock()
+	defer func() {
+		prober.lock.Lock()
+		delete(prober.waiters, key)
+		prober.lock.Unlock()
+	}()
+
+	syn := rawtcp.Segment{SrcPort: port, DstPort: remote.Port(), Seq: w.isn, Flags: rawtcp.FlagSYN, Window: synWindow}
+	err = prober.send(local, remote, &syn)
+	c.observer.OnConnectIssued(ctx, fd, err)
+	if err != nil {
+		return fmt.Errorf("error sending SYN: %w", err)
+	}
+	if c.debugEnabled(ctx) {
+		c.log().Debug("tcpshaker: SYN sent", "local", netip.AddrPortFrom(local, port), "remote", remote, "isn", w.isn)
+	}
+	return c.waitPipe(ctx, w.pipe)
+}
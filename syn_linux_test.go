@@ -0,0 +1,89 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startSYNChecker starts a Checker in raw SYN mode, the test is skipped
+// without CAP_NET_RAW. Run e.g. `unshare -rn sh -c 'ip link set lo up && go test'`
+// to test it in a network namespace without root.
+func startSYNChecker(t *testing.T) *Checker {
+	t.Helper()
+	fd, err := createRawSocket(syscall.AF_INET)
+	if err != nil {
+		t.Skipf("raw sockets are not available: %v", err)
+	}
+	syscall.Close(fd)
+
+	c := NewChecker(WithRawSYN(true))
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- c.CheckingLoop(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-stopped; err != nil {
+			t.Errorf("checking loop failed: %v", err)
+		}
+	})
+	select {
+	case <-c.WaitReady():
+	case err := <-stopped:
+		t.Fatalf("checking loop failed: %v", err)
+	}
+	return c
+}
+
+func TestRawSYN(t *testing.T) {
+	c := startSYNChecker(t)
+
+	for network, addr := range map[string]string{"tcp4": "127.0.0.1:0", "tcp6": "[::1]:0"} {
+		t.Run(network, func(t *testing.T) {
+			l, err := net.Listen(network, addr)
+			if err != nil {
+				t.Skipf("%s is not available: %v", network, err)
+			}
+			defer l.Close()
+
+			accepted := make(chan net.Conn, 1)
+			go func() {
+				if conn, err := l.Accept(); err == nil {
+					accepted <- conn
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := c.CheckAddrContext(ctx, l.Addr().String()); err != nil {
+				t.Fatalf("expected open port, got %v", err)
+			}
+			// The handshake is never completed, nothing could be accepted.
+			select {
+			case conn := <-accepted:
+				conn.Close()
+				t.Fatal("connection accepted by the listener")
+			case <-time.After(200 * time.Millisecond):
+			}
+
+			addr := l.Addr().String()
+			l.Close()
+			err = c.CheckAddrContext(ctx, addr)
+			var errConnect *ErrConnect
+			if !errors.As(err, &errConnect) || !errors.Is(err, syscall.ECONNREFUSED) {
+				t.Fatalf("expected ErrConnect(ECONNREFUSED), got %v", err)
+			}
+		})
+	}
+}
+
+func TestRawSYNNotRunning(t *testing.T) {
+	c := NewChecker(WithRawSYN(true))
+	err := c.CheckAddr("127.0.0.1:1", time.Second)
+	if !errors.Is(err, errSYNProberNotRunning) {
+		t.Fatalf("expected errSYNProberNotRunning, got %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+// Package throttle schedules checks so that no single endpoint, host or
+// subnet is flooded, which is essential for sweeps and large batch checks.
+//
+// A Limiter enforces concurrency and rate caps globally, per host(IP) and
+// per subnet(/24 for IPv4 and /64 for IPv6 by default), as well as a minimum
+// gap between probes to the same endpoint(IP and port).
+package throttle
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// Limits contains the caps enforced by a Limiter, zero values mean unlimited.
+type Limits struct {
+	// GlobalConcurrency is the maximum number of checks in flight.
+	GlobalConcurrency int
+	// GlobalRate is the maximum number of checks started per second.
+	GlobalRate float64
+	// HostConcurrency is the maximum number of checks in flight per IP.
+	HostConcurrency int
+	// HostRate is the maximum number of checks started per second per IP.
+	HostRate float64
+	// SubnetConcurrency is the maximum number of checks in flight per subnet.
+	SubnetConcurrency int
+	// SubnetRate is the maximum number of checks started per second per subnet.
+	SubnetRate float64
+	// SubnetBitsV4 is the prefix length of IPv4 subnets, 24 if zero.
+	SubnetBitsV4 int
+	// SubnetBitsV6 is the prefix length of IPv6 subnets, 64 if zero.
+	SubnetBitsV6 int
+	// EndpointGap is the minimum gap between the starts of checks to the same IP and port.
+	EndpointGap time.Duration
+}
+
+// Stats contains the throttling statistics of a Limiter.
+type Stats struct {
+	// Acquired is the number of checks allowed to start.
+	Acquired uint64
+	// Throttled is the number of checks delayed.
+	Throttled uint64
+	// TotalDelay is the sum of the delays of all checks.
+	TotalDelay time.Duration
+	// MaxDelay is the longest delay of a check.
+	MaxDelay time.Duration
+}
+
+// MeanDelay returns the mean delay of all checks.
+func (s Stats) MeanDelay() time.Duration {
+	if s.Acquired == 0 {
+		return 0
+	}
+	return s.TotalDelay / time.Duration(s.Acquired)
+}
+
+// pruneThreshold is the number of per-key states above which idle ones are removed.
+const pruneThreshold = 4096
+
+// Limiter enforces Limits on checks, it is safe for concurrent use.
+type Limiter struct {
+	limits Limits
+	l      sync.Mutex
+	global *state
+	keys   map[key]*state
+	stats  Stats
+}
+
+// key identifies a host, subnet or endpoint.
+type key struct {
+	kind   byte
+	prefix netip.Prefix
+	port   uint16
+}
+
+const (
+	kindHost byte = iota
+	kindSubnet
+	kindEndpoint
+)
+
+// state is the state of a global, host, subnet or endpoint limit.
+type state struct {
+	interval time.Duration
+	sem      chan struct{}
+	// next is the earliest time the next check could start.
+	next time.Time
+	// refs is the number of checks referencing this state.
+	refs int
+}
+
+func newState(concurrency int, interval time.Duration) *state {
+	s := &state{interval: interval}
+	if concurrency > 0 {
+		s.sem = make(chan struct{}, concurrency)
+	}
+	return s
+}
+
+func rateInterval(rate float64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / rate)
+}
+
+// New creates a Limiter enforcing given limits.
+func New(limits Limits) *Limiter {
+	if limits.SubnetBitsV4 <= 0 || limits.SubnetBitsV4 > 32 {
+		limits.SubnetBitsV4 = 24
+	}
+	if limits.SubnetBitsV6 <= 0 || limits.SubnetBitsV6 > 128 {
+		limits.SubnetBitsV6 = 64
+	}
+	return &Limiter{
+		limits: limits,
+		global: newState(limits.GlobalConcurrency, rateInterval(limits.GlobalRate)),
+		keys:   make(map[key]*state),
+	}
+}
+
+// Acquire blocks until a check to addr is allowed to start, the returned
+// release func must be called once the check is done.
+// ctx.Err() is returned if ctx is done before that.
+func (l *Limiter) Acquire(ctx context.Context, addr netip.AddrPort) (release func(), err error) {
+	requestedAt := time.Now()
+	ip := addr.Addr().Unmap()
+	subnetBits := l.limits.SubnetBitsV6
+	if ip.Is4() {
+		subnetBits = l.limits.SubnetBitsV4
+	}
+	subnet, _ := ip.Prefix(subnetBits)
+
+	states := []*state{l.global}
+	l.l.Lock()
+	states = append(states,
+		l.ref(key{kind: kindSubnet, prefix: subnet}, l.limits.SubnetConcurrency, rateInterval(l.limits.SubnetRate)),
+		l.ref(key{kind: kindHost, prefix: netip.PrefixFrom(ip, ip.BitLen())}, l.limits.HostConcurrency, rateInterval(l.limits.HostRate)),
+		l.ref(key{kind: kindEndpoint, prefix: netip.PrefixFrom(ip, ip.BitLen()), port: addr.Port()}, 0, l.limits.EndpointGap),
+	)
+	l.l.Unlock()
+
+	// Acquire the concurrency slots in a fixed order to avoid deadlocks.
+	acquired := 0
+	release = func() {
+		for _, s := range states[:acquired] {
+			if s.sem != nil {
+				<-s.sem
+			}
+		}
+		l.unref(states[1:])
+	}
+	for _, s := range states {
+		if s.sem != nil {
+			select {
+			case s.sem <- struct{}{}:
+			case <-ctx.Done():
+				release()
+				return nil, ctx.Err()
+			}
+		}
+		acquired++
+	}
+
+	// Reserve the earliest start time satisfying all the rate limits.
+	l.l.Lock()
+	startAt := time.Now()
+	for _, s := range states {
+		if s.next.After(startAt) {
+			startAt = s.next
+		}
+	}
+	for _, s := range states {
+		if s.interval > 0 {
+			s.next = startAt.Add(s.interval)
+		}
+	}
+	l.l.Unlock()
+
+	if wait := time.Until(startAt); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	l.record(time.Since(requestedAt))
+	return release, nil
+}
+
+// ref returns the state of k with its reference count increased.
+// NOTE: l.l must be held.
+func (l *Limiter) ref(k key, concurrency int, interval time.Duration) *state {
+	s, ok := l.keys[k]
+	if !ok {
+		if len(l.keys) >= pruneThreshold {
+			l.prune()
+		}
+		s = newState(concurrency, interval)
+		l.keys[k] = s
+	}
+	s.refs++
+	return s
+}
+
+func (l *Limiter) unref(states []*state) {
+	l.l.Lock()
+	for _, s := range states {
+		s.refs--
+	}
+	l.l.Unlock()
+}
+
+// prune removes the states no longer affecting any check.
+// NOTE: l.l must be held.
+func (l *Limiter) prune() {
+	now := time.Now()
+	for k, s := range l.keys {
+		if s.refs == 0 && !s.next.After(now) {
+			delete(l.keys, k)
+		}
+	}
+}
+
+// throttledThreshold is the delay above which a check is considered throttled.
+const throttledThreshold = time.Millisecond
+
+func (l *Limiter) record(delay time.Duration) {
+	l.l.Lock()
+	l.stats.Acquired++
+	l.stats.TotalDelay += delay
+	if delay > throttledThreshold {
+		l.stats.Throttled++
+	}
+	if delay > l.stats.MaxDelay {
+		l.stats.MaxDelay = delay
+	}
+	l.l.Unlock()
+}
+
+// Stats returns the throttling statistics.
+func (l *Limiter) Stats() Stats {
+	l.l.Lock()
+	defer l.l.Unlock()
+	return l.stats
+}
+
+// Checker performs checks, it is implemented by tcpshaker.Checker.
+type Checker interface {
+	CheckAddrContext(ctx context.Context, addr string) error
+}
+
+// Check resolves addr, waits until the check is allowed and performs it
+// with checker. The timeout applies to the check only, not the throttling
+// delay, which is bounded by ctx.
+func (l *Limiter) Check(ctx context.Context, checker Checker, addr string, timeout time.Duration) error {
+	ap, err := Resolve(ctx, addr)
+	if err != nil {
+		return err
+	}
+	release, err := l.Acquire(ctx, ap)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return checker.CheckAddrContext(ctx, ap.String())
+}
+
+// Resolve resolves a "host:port" address to an IP and port,
+// IPv4 addresses are preferred like net.ResolveTCPAddr does.
+func Resolve(ctx context.Context, addr string) (netip.AddrPort, error) {
+	if ap, err := netip.ParseAddrPort(addr); err == nil {
+		return netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port()), nil
+	}
+	host, service, err := net.SplitHostPort(addr)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	port, err := net.DefaultResolver.LookupPort(ctx, "tcp", service)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	ip := ips[0]
+	for _, candidate := range ips {
+		if candidate.Unmap().Is4() {
+			ip = candidate
+			break
+		}
+	}
+	return netip.AddrPortFrom(ip.Unmap(), uint16(port)), nil
+}
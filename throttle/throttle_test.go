@@ -0,0 +1,165 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	endpointA = netip.MustParseAddrPort("10.0.0.1:80")
+	endpointB = netip.MustParseAddrPort("10.0.0.1:443")
+	endpointC = netip.MustParseAddrPort("10.0.0.2:80")
+	endpointD = netip.MustParseAddrPort("10.0.1.1:80")
+)
+
+func acquire(t *testing.T, l *Limiter, addr netip.AddrPort) func() {
+	release, err := l.Acquire(context.Background(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return release
+}
+
+// acquireTimes returns the time each of addrs is acquired sequentially.
+func acquireTimes(t *testing.T, l *Limiter, addrs ...netip.AddrPort) []time.Duration {
+	startedAt := time.Now()
+	var times []time.Duration
+	for _, addr := range addrs {
+		acquire(t, l, addr)()
+		times = append(times, time.Since(startedAt))
+	}
+	return times
+}
+
+func TestEndpointGap(t *testing.T) {
+	gap := 50 * time.Millisecond
+	l := New(Limits{EndpointGap: gap})
+	times := acquireTimes(t, l, endpointA, endpointB, endpointA)
+	if times[1] >= gap {
+		t.Fatalf("different endpoints should not be throttled: %v", times)
+	}
+	if times[2] < gap {
+		t.Fatalf("the gap between probes to the same endpoint is not enforced: %v", times)
+	}
+	stats := l.Stats()
+	if stats.Acquired != 3 || stats.Throttled != 1 || stats.MaxDelay < gap/2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestSubnetRate(t *testing.T) {
+	interval := 50 * time.Millisecond
+	l := New(Limits{SubnetRate: float64(time.Second / interval)})
+	times := acquireTimes(t, l, endpointA, endpointD, endpointC)
+	if times[1] >= interval {
+		t.Fatalf("different subnets should not be throttled: %v", times)
+	}
+	if times[2] < interval {
+		t.Fatalf("the subnet rate is not enforced: %v", times)
+	}
+}
+
+func TestHostConcurrency(t *testing.T) {
+	l := New(Limits{HostConcurrency: 1})
+	release := acquire(t, l, endpointA)
+	// other hosts are not affected
+	acquire(t, l, endpointC)()
+
+	acquired := make(chan func())
+	go func() {
+		release, _ := l.Acquire(context.Background(), endpointB)
+		acquired <- release
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("host concurrency is not enforced")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	(<-acquired)()
+}
+
+func TestGlobalConcurrency(t *testing.T) {
+	l := New(Limits{GlobalConcurrency: 2})
+	var wg sync.WaitGroup
+	var inflight, maxInflight int
+	var mu sync.Mutex
+	for _, addr := range []netip.AddrPort{endpointA, endpointB, endpointC, endpointD} {
+		wg.Add(1)
+		go func(addr netip.AddrPort) {
+			defer wg.Done()
+			release := acquire(t, l, addr)
+			mu.Lock()
+			inflight++
+			maxInflight = max(maxInflight, inflight)
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			inflight--
+			mu.Unlock()
+			release()
+		}(addr)
+	}
+	wg.Wait()
+	if maxInflight != 2 {
+		t.Fatalf("expected 2 checks in flight at most, got %d", maxInflight)
+	}
+}
+
+func TestAcquireCanceled(t *testing.T) {
+	l := New(Limits{HostConcurrency: 1})
+	release := acquire(t, l, endpointA)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, endpointA); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	release()
+	acquire(t, l, endpointA)()
+}
+
+func TestPrune(t *testing.T) {
+	l := New(Limits{})
+	for i := 0; i < pruneThreshold+10; i++ {
+		addr := netip.AddrPortFrom(netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)}), 80)
+		acquire(t, l, addr)()
+	}
+	l.l.Lock()
+	defer l.l.Unlock()
+	if len(l.keys) > pruneThreshold {
+		t.Fatalf("idle states are not pruned: %d", len(l.keys))
+	}
+}
+
+type fakeChecker struct {
+	addrs    []string
+	deadline time.Time
+}
+
+func (c *fakeChecker) CheckAddrContext(ctx context.Context, addr string) error {
+	c.addrs = append(c.addrs, addr)
+	c.deadline, _ = ctx.Deadline()
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	l := New(Limits{})
+	checker := &fakeChecker{}
+	if err := l.Check(context.Background(), checker, "localhost:80", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(checker.addrs) != 1 || checker.addrs[0] != "127.0.0.1:80" {
+		t.Fatalf("unexpected address checked: %v", checker.addrs)
+	}
+	if time.Until(checker.deadline) > time.Second {
+		t.Fatal("timeout is not applied")
+	}
+	if err := l.Check(context.Background(), checker, "localhost", time.Second); err == nil {
+		t.Fatal("expected an error for invalid address")
+	}
+}
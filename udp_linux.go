@@ -0,0 +1,95 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// CheckUDP checks a UDP port by sending payload from a connected UDP socket.
+// A response means the port is open, nil is returned.
+// An ICMP port unreachable means it is closed, ErrConnect wrapping
+// ECONNREFUSED is returned, other ICMP errors are reported as ErrConnect too.
+// ErrOpenFiltered is returned if nothing is received before the deadline of ctx.
+// NOTE: without a deadline set on ctx, the check waits until ctx is canceled.
+func (c *Checker) CheckUDP(ctx context.Context, addr string, payload []byte) (err error) {
+	startedAt := time.Now()
+	ctx = c.observer.OnCheckStart(contextWithMode(ctx, ModeUDP), addr)
+	defer func() {
+		c.localStats.count(err)
+		c.observer.OnCheckDone(ctx, err, time.Since(startedAt))
+	}()
+	return c.checkUDP(ctx, addr, payload)
+}
+
+func (c *Checker) checkUDP(ctx context.Context, addr string, payload []byte) error {
+	rAddr, family, err := parseUDPSockAddr(addr)
+	if err != nil {
+		return err
+	}
+	c.observer.OnResolved(ctx, sockaddrToTCPAddr(rAddr))
+	fd, err := createUDPSocket(family)
+	if err != nil {
+		if c.debugEnabled(ctx) {
+			c.log().Debug("tcpshaker: error creating UDP socket", "addr", addr, "error", err)
+		}
+		if localErr := localError(err); localErr != nil {
+			return localErr
+		}
+		return err
+	}
+	defer unix.Close(fd)
+	c.observer.OnSocketCreated(ctx, fd)
+
+	if err := unix.Connect(fd, rAddr); err != nil {
+		return localOrConnectError(err)
+	}
+
+	resultPipe := c.pipePool.GetPipe()
+	defer func() {
+		c.resultPipes.DeRegisterResultPipe(fd)
+		c.pipePool.PutBackPipe(resultPipe)
+	}()
+	c.resultPipes.RegisterResultPipe(fd, resultPipe)
+	// The socket is always writable, only the responses and errors are waited.
+	if err := c.registerCheck(fd, registerReadEvents); err != nil {
+		if c.debugEnabled(ctx) {
+			c.log().Debug("tcpshaker: error registering events", "fd", fd, "error", err)
+		}
+		return err
+	}
+
+	_, err = unix.Write(fd, payload)
+	c.observer.OnConnectIssued(ctx, fd, err)
+	if err != nil {
+		return &ErrConnect{err}
+	}
+
+	err = c.waitPipe(ctx, resultPipe)
+	if errors.Is(err, ErrTimeout) {
+		return ErrOpenFiltered
+	}
+	return err
+}
+
+// createUDPSocket creates a non-blocking UDP socket receiving ICMP errors.
+func createUDPSocket(family int) (int, error) {
+	fd, err := unix.Socket(family, unix.SOCK_DGRAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return -1, os.NewSyscallError("socket", err)
+	}
+	// Report all the ICMP errors instead of the hard ones only.
+	if family == unix.AF_INET6 {
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_RECVERR, 1)
+	} else {
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_RECVERR, 1)
+	}
+	if err != nil {
+		unix.Close(fd)
+		return -1, os.NewSyscallError("setsockopt", err)
+	}
+	return fd, nil
+}
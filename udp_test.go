@@ -0,0 +1,83 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startUDPServer starts a UDP server which responds only to "ping".
+func startUDPServer(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if string(buf[:n]) == "ping" {
+				_, _ = conn.WriteTo([]byte("pong"), addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestCheckUDP(t *testing.T) {
+	t.Parallel()
+	c := NewChecker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	check := func(addr string, payload []byte) error {
+		checkCtx, checkCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer checkCancel()
+		return c.CheckUDP(checkCtx, addr, payload)
+	}
+	addr := startUDPServer(t)
+
+	// open
+	if err := check(addr, []byte("ping")); err != nil {
+		t.Fatalf("expected open, got %v", err)
+	}
+	// silence
+	err := check(addr, []byte("hello"))
+	if err != ErrOpenFiltered || !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrOpenFiltered, got %v", err)
+	}
+	// closed
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := conn.LocalAddr().String()
+	conn.Close()
+	err = check(closedAddr, []byte("ping"))
+	var errConnect *ErrConnect
+	if !errors.As(err, &errConnect) || !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("expected ErrConnect(ECONNREFUSED), got %v", err)
+	}
+
+	// ModeUDP
+	c2 := NewChecker(WithMode(ModeUDP), WithUDPPayload([]byte("ping")))
+	go func() {
+		_ = c2.CheckingLoop(ctx)
+	}()
+	<-c2.WaitReady()
+	if err := c2.CheckAddr(addr, time.Second); err != nil {
+		t.Fatalf("expected open, got %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+package tcp
+
+import "strings"
+
+// unixScheme is the prefix of the addresses of Unix domain sockets.
+const unixScheme = "unix:"
+
+// ParseUnixAddr returns the name of the Unix domain socket addressed by addr,
+// e.g. "/run/app.sock" of "unix:///run/app.sock" or "unix:/run/app.sock" and
+// "@app" of the abstract "unix:@app". ok is false if addr is not the address of
+// a Unix domain socket, the name must be an absolute path or an abstract name
+// so that a TCP address of a host named "unix" like "unix:80" is not mistaken.
+func ParseUnixAddr(addr string) (name string, ok bool) {
+	name, ok = strings.CutPrefix(addr, unixScheme)
+	if !ok {
+		return "", false
+	}
+	// "unix:///path" is the URL form of "unix:/path".
+	name = strings.TrimPrefix(name, "//")
+	if len(name) < 2 || (name[0] != '/' && name[0] != '@') {
+		return "", false
+	}
+	return name, true
+}
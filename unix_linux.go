@@ -0,0 +1,33 @@
+package tcp
+
+import (
+	"context"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// checkUnix connects to the Unix domain socket of given name,
+// a name starting with '@' is in the abstract namespace.
+func (c *Checker) checkUnix(ctx context.Context, name string) error {
+	fd, err := unix.Socket(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		if c.debugEnabled(ctx) {
+			c.log().Debug("tcpshaker: error creating socket", "addr", name, "error", err)
+		}
+		return os.NewSyscallError("socket", err)
+	}
+	defer unix.Close(fd)
+	c.observer.OnSocketCreated(ctx, fd)
+
+	// The connect of Unix domain sockets usually completes immediately,
+	// EAGAIN is returned instead of waiting if the backlog is full.
+	success, cErr := connect(fd, &unix.SockaddrUnix{Name: name})
+	c.observer.OnConnectIssued(ctx, fd, cErr)
+	if cErr != nil {
+		return unixConnectError(cErr)
+	} else if success {
+		return nil
+	}
+	return c.waitConnectResult(ctx, fd)
+}
@@ -0,0 +1,69 @@
+package tcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCheckUnixAbstract(t *testing.T) {
+	t.Parallel()
+	c := NewChecker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	name := fmt.Sprintf("@tcp-shaker-test-%d", os.Getpid())
+	l, err := net.Listen("unix", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if err := c.CheckAddr("unix:"+name, time.Second); err != nil {
+		t.Fatalf("expected open, got %v", err)
+	}
+}
+
+func TestCheckUnixBacklogFull(t *testing.T) {
+	t.Parallel()
+	c := NewChecker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	// A listener with a backlog of 0 which never accepts.
+	fd, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(fd)
+	name := fmt.Sprintf("@tcp-shaker-backlog-%d", os.Getpid())
+	if err := syscall.Bind(fd, &syscall.SockaddrUnix{Name: name}); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Listen(fd, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// The connections queued by the checks are never accepted.
+	for i := 0; i < 8; i++ {
+		err = c.CheckAddr("unix:"+name, time.Second)
+		if err != nil {
+			break
+		}
+	}
+	if err != ErrBacklogFull {
+		t.Fatalf("expected ErrBacklogFull, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+//go:build unix
+
+package tcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseUnixAddr(t *testing.T) {
+	for addr, expected := range map[string]string{
+		"unix:///run/app.sock": "/run/app.sock",
+		"unix:/run/app.sock":   "/run/app.sock",
+		"unix:@app":            "@app",
+		"unix://@app":          "@app",
+		"unix:app.sock":        "",
+		"unix:80":              "",
+		"unix://80":            "",
+		"unix:@":               "",
+		"unix:/":               "",
+		"unix:":                "",
+		"unix://":              "",
+		"127.0.0.1:80":         "",
+		"unixhost:80":          "",
+	} {
+		name, ok := ParseUnixAddr(addr)
+		if name != expected || ok != (expected != "") {
+			t.Errorf("ParseUnixAddr(%q) = %q, %v; expected %q", addr, name, ok, expected)
+		}
+	}
+}
+
+func TestCheckUnix(t *testing.T) {
+	t.Parallel()
+	c := NewChecker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.CheckingLoop(ctx)
+	}()
+	<-c.WaitReady()
+
+	path := filepath.Join(t.TempDir(), "app.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("Unix domain sockets are not available: %v", err)
+	}
+	// Keep the file to check the refusal of a socket without a listener.
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	defer l.Close()
+
+	// open
+	if err := c.CheckAddr("unix://"+path, time.Second); err != nil {
+		t.Fatalf("expected open, got %v", err)
+	}
+	// refused
+	l.Close()
+	err = c.CheckAddr("unix://"+path, time.Second)
+	var errConnect *ErrConnect
+	if !errors.As(err, &errConnect) || !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("expected ErrConnect(ECONNREFUSED), got %v", err)
+	}
+	// not found
+	err = c.CheckAddr("unix:"+path+".missing", time.Second)
+	if err != ErrSocketNotFound || !errors.Is(err, syscall.ENOENT) {
+		t.Fatalf("expected ErrSocketNotFound, got %v", err)
+	}
+}